@@ -0,0 +1,146 @@
+package postmark
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const __POSTMARK_SERVERS_PATH__ string = "/servers"
+
+// The most servers Postmark lists in one page
+const MaxServerCount int = 500
+
+// An AccountClient calls Postmark's account-level
+// APIs, such as domains, sender signatures and
+// servers, with an account token. It is a
+// separate type from Client, which holds a
+// server token, so the two can't be mixed up.
+// Once created, it is safe to share between
+// goroutines
+type AccountClient struct {
+	client *Client
+}
+
+// Create a new AccountClient with a Postmark
+// account token and any options, as NewClient
+// does, and return a pointer to it. Options
+// that only apply to sending with a server
+// token, such as WithTokens, WithSandbox and
+// WithMessageStream, are rejected
+func NewAccountClient(token string, opts ...Option) (*AccountClient, error) {
+	if strings.TrimSpace(token) == "" {
+		return nil, fmt.Errorf("Cannot create an account client without an account token")
+	}
+
+	c := newClient(token)
+	c.tokenHeader = __ACCOUNT_TOKEN_HEADER__
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkAccountOptions(c, token); err != nil {
+		return nil, err
+	}
+
+	return &AccountClient{client: c}, nil
+}
+
+// Checks that no option meant for a server
+// token's client configured c
+func checkAccountOptions(c *Client, token string) error {
+	var options []string
+	if c.apiKey == SandboxToken {
+		options = append(options, "WithSandbox")
+	}
+	if c.tokens != nil || c.tokenSelector != nil || c.tokenFailover || (c.apiKey != token && c.apiKey != SandboxToken) {
+		options = append(options, "WithTokens")
+	}
+	if c.messageStream != "" {
+		options = append(options, "WithMessageStream")
+	}
+	if c.markdown != nil {
+		options = append(options, "WithMarkdownRenderer")
+	}
+	if c.skipAddressCheck {
+		options = append(options, "WithoutAddressValidation")
+	}
+	if len(options) > 0 {
+		return fmt.Errorf("Cannot create an account client with server token options (%s)", strings.Join(options, ", "))
+	}
+	return nil
+}
+
+// Create a new AccountClient as NewAccountClient
+// does, with the account token in
+// POSTMARK_ACCOUNT_TOKEN, trimmed of
+// surrounding whitespace
+func NewAccountClientFromEnv(opts ...Option) (*AccountClient, error) {
+	token := strings.TrimSpace(os.Getenv(AccountTokenEnv))
+	if token == "" {
+		return nil, fmt.Errorf("Cannot create an account client without an account token (%s environment variable)", AccountTokenEnv)
+	}
+
+	return NewAccountClient(token, opts...)
+}
+
+// A server on the account, as listed by
+// AccountClient.ListServers
+type Server struct {
+	ID               int64
+	Name             string
+	ApiTokens        []string
+	Color            string
+	ServerLink       string
+	DeliveryType     string
+	SmtpApiActivated bool
+	TrackOpens       bool
+	TrackLinks       LinkTracking
+	InboundAddress   string
+}
+
+// A page of servers and the total number on
+// the account
+type ServerList struct {
+	TotalCount int
+	Servers    []Server
+}
+
+// Returns a page of up to count of the
+// account's servers, from offset, with names
+// containing name when it is set. A count of
+// zero lists MaxServerCount
+func (a *AccountClient) ListServers(count, offset int, name string) (*ServerList, error) {
+	return a.ListServersContext(context.Background(), count, offset, name)
+}
+
+// Same as ListServers, but the request is bound
+// to ctx
+func (a *AccountClient) ListServersContext(ctx context.Context, count, offset int, name string) (*ServerList, error) {
+	if count == 0 {
+		count = MaxServerCount
+	}
+	if count < 0 || count > MaxServerCount {
+		return nil, fmt.Errorf("Cannot list %d servers, the limit is %d", count, MaxServerCount)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("Cannot list servers from a negative offset")
+	}
+
+	values := url.Values{}
+	values.Set("count", strconv.Itoa(count))
+	values.Set("offset", strconv.Itoa(offset))
+	if name != "" {
+		values.Set("name", name)
+	}
+
+	list := &ServerList{}
+	if err := a.client.getJSON(ctx, __POSTMARK_SERVERS_PATH__+"?"+values.Encode(), list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
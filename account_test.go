@@ -0,0 +1,96 @@
+package postmark
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccountClient(t *testing.T) {
+	var serverToken, accountToken, path string
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverToken = r.Header.Get("X-Postmark-Server-Token")
+		accountToken = r.Header.Get("X-Postmark-Account-Token")
+		path, query = r.URL.Path, r.URL.Query()
+		w.Write([]byte(`{
+			"TotalCount": 1,
+			"Servers": [{
+				"ID": 1,
+				"Name": "Production01",
+				"ApiTokens": ["fafe4b35-b4d9-4c4b-b1e3-7a4b3e5c2a1f"],
+				"Color": "red",
+				"DeliveryType": "Live",
+				"TrackLinks": "HtmlAndText"
+			}]
+		}`))
+	}))
+	defer server.Close()
+
+	a, err := NewAccountClient("7654321", WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Unexpected error creating account client: %s", err)
+	}
+	list, err := a.ListServers(10, 20, "Production")
+	if err != nil {
+		t.Fatalf("Unexpected error listing servers: %s", err)
+	}
+	if accountToken != "7654321" || serverToken != "" {
+		t.Errorf("Expected only the account token header, got server %q and account %q", serverToken, accountToken)
+	}
+	if path != "/servers" || query.Get("count") != "10" || query.Get("offset") != "20" || query.Get("name") != "Production" {
+		t.Errorf("Unexpected request %s?%s", path, query.Encode())
+	}
+	if list.TotalCount != 1 || len(list.Servers) != 1 || list.Servers[0].Name != "Production01" || list.Servers[0].TrackLinks != LinkTrackingHTMLAndText {
+		t.Errorf("Unexpected servers %+v", list)
+	}
+
+	if _, err := a.ListServers(0, 0, ""); err != nil || query.Get("count") != "500" || query.Has("name") {
+		t.Errorf("Expected the default count and no name filter, got %s and %v", query.Encode(), err)
+	}
+	if _, err := a.ListServers(501, 0, ""); err == nil {
+		t.Errorf("Expected an error for a count over the limit")
+	}
+	if _, err := a.ListServers(10, -1, ""); err == nil {
+		t.Errorf("Expected an error for a negative offset")
+	}
+
+	if _, err := NewAccountClient(" "); err == nil {
+		t.Errorf("Expected an error for a blank account token")
+	}
+}
+
+func TestAccountClientServerOptions(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"WithSandbox":       WithSandbox(),
+		"WithTokens":        WithTokens("a", "b"),
+		"WithMessageStream": WithMessageStream(MessageStreamBroadcast),
+	} {
+		if _, err := NewAccountClient("7654321", opt); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected %s to be rejected, got %v", name, err)
+		}
+	}
+
+	if _, err := NewAccountClient("7654321", WithTimeout(time.Second), WithRetries(2, time.Millisecond)); err != nil {
+		t.Errorf("Unexpected error with general options: %s", err)
+	}
+}
+
+func TestNewAccountClientFromEnv(t *testing.T) {
+	t.Setenv(AccountTokenEnv, "")
+	if _, err := NewAccountClientFromEnv(); err == nil {
+		t.Errorf("Expected an error when %s is unset", AccountTokenEnv)
+	}
+
+	t.Setenv(AccountTokenEnv, " 7654321\n")
+	a, err := NewAccountClientFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error creating account client: %s", err)
+	}
+	if a.client.apiKey != "7654321" {
+		t.Errorf("Expected the trimmed token, got %q", a.client.apiKey)
+	}
+}
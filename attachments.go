@@ -0,0 +1,498 @@
+package postmark
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Postmark's limit on the total size of a
+// message's attachments, 10MB
+const DefaultMaxAttachmentBytes int64 = 10 * 1024 * 1024
+
+// Postmark's limit on the size of a whole
+// message, 10MB, counting its bodies and its
+// attachments as they are sent, in base64
+const DefaultMaxMessageBytes int64 = 10 * 1024 * 1024
+
+// The default limit on the size of a download
+// by AddAttachmentFromURL, 10MB
+const DefaultMaxDownloadBytes int64 = 10 * 1024 * 1024
+
+// How long AddAttachmentFromURL waits for a
+// download when the message's client uses
+// http.DefaultClient, which has no timeout
+const DefaultDownloadTimeout time.Duration = time.Minute
+
+type attachment struct {
+	Name        string
+	Content     string
+	ContentType string
+	ContentID   string `json:",omitempty"`
+
+	size int64
+}
+
+// A summary of an attachment on a message, as
+// returned by PMMail.Attachments, without its
+// content
+type AttachmentInfo struct {
+	Name        string
+	ContentType string
+
+	// The "cid:" reference of an inline
+	// attachment, or empty
+	ContentID string
+
+	// The size of the content before base64
+	// encoding
+	Size int64
+}
+
+// Add a file attachment by file path
+// Most shamefully inspired by
+// https://github.com/gcmurphy/postmark/blob/master/message.go
+func (p *PMMail) AddAttachment(file string) error {
+	return p.AddAttachmentAs(file, "")
+}
+
+// Add a file attachment by file path, sent as
+// name rather than the file's own name, such as
+// for a temporary file. An empty name uses the
+// file's name
+func (p *PMMail) AddAttachmentAs(file, name string) error {
+	fileHandle, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fileHandle.Close()
+
+	fileInfo, err := fileHandle.Stat()
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = fileInfo.Name()
+	}
+
+	mimeType := mime.TypeByExtension(path.Ext(file))
+	if len(mimeType) == 0 {
+		mimeType = "application/octet-stream"
+	}
+
+	// The size only reserves space for the
+	// encoding; the limit is enforced while
+	// reading, for files whose size is unknown
+	return p.addAttachmentFromReader(name, fileHandle, mimeType, fileInfo.Size())
+}
+
+// Add a file attachment for each path, as
+// AddAttachment does. Every path is tried, and
+// the error joins those of every file that
+// couldn't be attached, including directories.
+// The files that could be are still attached
+func (p *PMMail) AddAttachments(paths ...string) error {
+	var errs []error
+	for _, file := range paths {
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			errs = append(errs, fmt.Errorf("Cannot attach directory %s", file))
+			continue
+		}
+		if err := p.AddAttachment(file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Add a file attachment for each file matching
+// pattern, in filepath.Glob's syntax, as
+// AddAttachments does. Directories that match
+// are skipped, and matching no files is an
+// error, so a mistyped pattern doesn't send
+// mail without its attachments
+func (p *PMMail) AddAttachmentsGlob(pattern string) error {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("Cannot attach files matching %s: %s", pattern, err)
+	}
+
+	var files []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			continue
+		}
+		files = append(files, match)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("Cannot attach files matching %s: no files match", pattern)
+	}
+
+	return p.AddAttachments(files...)
+}
+
+// Add a file attachment from fsys, such as an
+// embed.FS of assets compiled into the binary,
+// as AddAttachment does for the OS's files
+func (p *PMMail) AddAttachmentFS(fsys fs.FS, file string) error {
+	fileHandle, err := fsys.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fileHandle.Close()
+
+	fileInfo, err := fileHandle.Stat()
+	if err != nil {
+		return err
+	}
+	if fileInfo.IsDir() {
+		return fmt.Errorf("Cannot attach directory %s", file)
+	}
+
+	mimeType := mime.TypeByExtension(path.Ext(file))
+	if len(mimeType) == 0 {
+		mimeType = "application/octet-stream"
+	}
+
+	return p.addAttachmentFromReader(fileInfo.Name(), fileHandle, mimeType, fileInfo.Size())
+}
+
+// Add an attachment downloaded from rawURL,
+// such as a PDF from an internal service. The
+// download is made with the HTTP client the
+// message is sent with, so WithHTTPClient's
+// transport, proxy and TLS settings apply, or
+// within DefaultDownloadTimeout if that is
+// http.DefaultClient. It is streamed, and fails
+// once it passes the smaller of
+// MaxDownloadBytes and the attachment limit.
+// The response's Content-Type is used when it
+// has one, and an empty name uses the last
+// element of the URL's path. A response other
+// than 200 OK is an error with its status
+func (p *PMMail) AddAttachmentFromURL(rawURL, name string) error {
+	return p.AddAttachmentFromURLContext(context.Background(), rawURL, name)
+}
+
+// Same as AddAttachmentFromURL, but the
+// download is bound to ctx
+func (p *PMMail) AddAttachmentFromURLContext(ctx context.Context, rawURL, name string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("Cannot attach from invalid URL: %s", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Cannot attach from %s URL %s", u.Scheme, pathOnly(u.Redacted()))
+	}
+	if name == "" {
+		name = path.Base(u.Path)
+		if name == "/" || name == "." {
+			return fmt.Errorf("Cannot attach from %s without a name", pathOnly(u.Redacted()))
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := p.downloadClient().Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Cannot attach %s: HTTP error %d : %s", pathOnly(u.Redacted()), response.StatusCode, http.StatusText(response.StatusCode))
+	}
+
+	limit, attachmentLimit := p.maxDownloadBytes(), p.maxAttachmentBytes()
+	if attachmentLimit < limit {
+		limit = attachmentLimit
+	}
+	content, size, err := encodeAttachment(response.Body, limit, response.ContentLength)
+	if err != nil {
+		return err
+	}
+	if size > limit {
+		if limit == attachmentLimit {
+			return fmt.Errorf("Attachment %s exceeds the %d byte attachment limit.", name, limit)
+		}
+		return fmt.Errorf("Download of %s exceeds the %d byte download limit (.MaxDownloadBytes field)", name, limit)
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	if len(contentType) == 0 {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+
+	return p.addAttachment(name, content, size, contentType)
+}
+
+// Add an attachment read from r, such as a file
+// generated in memory or downloaded from storage.
+// The content is streamed through a base64
+// encoder, and the reader doesn't need to be
+// seekable, as the size limit is enforced while
+// reading. An empty contentType is sent as
+// application/octet-stream
+func (p *PMMail) AddAttachmentFromReader(name string, r io.Reader, contentType string) error {
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+	return p.addAttachmentFromReader(name, r, contentType, 0)
+}
+
+// Encodes and adds an attachment read from r,
+// of about sizeHint bytes if that is known
+func (p *PMMail) addAttachmentFromReader(name string, r io.Reader, contentType string, sizeHint int64) error {
+	limit := p.maxAttachmentBytes()
+	content, size, err := encodeAttachment(r, limit, sizeHint)
+	if err != nil {
+		return err
+	}
+	if size > limit {
+		return fmt.Errorf("Attachment %s exceeds the %d byte attachment limit.", name, limit)
+	}
+
+	return p.addAttachment(name, content, size, contentType)
+}
+
+// Add an attachment from content already in
+// memory, such as a rendered invoice. An empty
+// contentType is sent as application/octet-stream
+func (p *PMMail) AddAttachmentFromBytes(name string, data []byte, contentType string) error {
+	if limit := p.maxAttachmentBytes(); int64(len(data)) > limit {
+		return fmt.Errorf("File size %d exceeds the %d byte attachment limit.", len(data), limit)
+	}
+
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+
+	return p.addAttachment(name, base64.StdEncoding.EncodeToString(data), int64(len(data)), contentType)
+}
+
+// Add an attachment from content already in
+// memory, as AddAttachmentFromBytes does, but
+// with an empty contentType detected from the
+// content by http.DetectContentType
+func (p *PMMail) AddAttachmentBytes(name, contentType string, data []byte) error {
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(data)
+	}
+	return p.AddAttachmentFromBytes(name, data, contentType)
+}
+
+// Add an inline attachment, such as an image
+// shown by the HTML body, from content in
+// memory, as AddAttachmentBytes does. The HTML
+// refers to it as "cid:" followed by contentID,
+// as in <img src="cid:logo">. Inline
+// attachments count towards the attachment
+// limit along with the rest
+func (p *PMMail) AddInlineAttachment(name, contentType, contentID string, data []byte) error {
+	if err := checkContentID(contentID); err != nil {
+		return err
+	}
+	if err := p.AddAttachmentBytes(name, contentType, data); err != nil {
+		return err
+	}
+	p.attachments[len(p.attachments)-1].ContentID = cid(contentID)
+	return nil
+}
+
+// Add an inline attachment by file path, as
+// AddInlineAttachment does for content in
+// memory
+func (p *PMMail) AddInlineAttachmentFile(file, contentID string) error {
+	if err := checkContentID(contentID); err != nil {
+		return err
+	}
+	if err := p.AddAttachment(file); err != nil {
+		return err
+	}
+	p.attachments[len(p.attachments)-1].ContentID = cid(contentID)
+	return nil
+}
+
+func checkContentID(contentID string) error {
+	if strings.TrimPrefix(contentID, "cid:") == "" {
+		return fmt.Errorf("Cannot add an inline attachment without a content ID")
+	}
+	if strings.ContainsAny(contentID, "\r\n\"<> ") {
+		return fmt.Errorf("Cannot add an inline attachment with invalid content ID %q", contentID)
+	}
+	return nil
+}
+
+// Returns a content ID as Postmark expects it,
+// prefixed with "cid:"
+func cid(contentID string) string {
+	if strings.HasPrefix(contentID, "cid:") {
+		return contentID
+	}
+	return "cid:" + contentID
+}
+
+// Returns summaries of the attachments, in the
+// order they were added
+func (p *PMMail) Attachments() []AttachmentInfo {
+	if len(p.attachments) == 0 {
+		return nil
+	}
+	infos := make([]AttachmentInfo, len(p.attachments))
+	for i, a := range p.attachments {
+		infos[i] = AttachmentInfo{
+			Name:        a.Name,
+			ContentType: a.ContentType,
+			ContentID:   a.ContentID,
+			Size:        a.size,
+		}
+	}
+	return infos
+}
+
+// Remove the first attachment with the given
+// name, reporting whether one was removed
+func (p *PMMail) RemoveAttachment(name string) bool {
+	for i, a := range p.attachments {
+		if a.Name == name {
+			p.attachments = slices.Delete(p.attachments, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// Remove every attachment from the email message
+func (p *PMMail) ClearAttachments() {
+	p.attachments = nil
+}
+
+// Adds an attachment whose content is already
+// base64 encoded from size bytes, unless it
+// would take the message over its size limit
+func (p *PMMail) addAttachment(name string, content string, size int64, contentType string) error {
+	if total, limit := p.messageBytes()+int64(len(content)), p.maxMessageBytes(); total > limit {
+		return fmt.Errorf("Cannot add attachment %s, the message would be %d bytes, the limit is %d (.MaxMessageBytes field)", name, total, limit)
+	}
+
+	a := attachment{
+		Name:        name,
+		Content:     content,
+		ContentType: contentType,
+		size:        size,
+	}
+	p.attachments = append(p.attachments, a)
+	return nil
+}
+
+// Base64 encodes up to limit+1 bytes read from
+// r a chunk at a time, so only the encoded
+// content is ever held in memory, and returns
+// it with the number of bytes read. A size
+// over limit means r held too much. sizeHint
+// presizes the result when the size is known
+func encodeAttachment(r io.Reader, limit, sizeHint int64) (string, int64, error) {
+	var encoded strings.Builder
+	if sizeHint > 0 && sizeHint <= limit {
+		encoded.Grow(base64.StdEncoding.EncodedLen(int(sizeHint)))
+	}
+
+	encoder := base64.NewEncoder(base64.StdEncoding, &encoded)
+	size, err := io.Copy(encoder, io.LimitReader(r, limit+1))
+	if err != nil {
+		return "", size, err
+	}
+	if size > limit {
+		return "", size, nil
+	}
+	if err := encoder.Close(); err != nil {
+		return "", size, err
+	}
+
+	return encoded.String(), size, nil
+}
+
+// Returns the HTTP client AddAttachmentFromURL
+// downloads with
+func (p *PMMail) downloadClient() *http.Client {
+	hc := http.DefaultClient
+	if p.client != nil && p.client.httpClient != nil {
+		hc = p.client.httpClient
+	}
+	if hc == http.DefaultClient {
+		return &http.Client{Timeout: DefaultDownloadTimeout}
+	}
+	return hc
+}
+
+func (p *PMMail) maxDownloadBytes() int64 {
+	if p.MaxDownloadBytes > 0 {
+		return p.MaxDownloadBytes
+	}
+	return DefaultMaxDownloadBytes
+}
+
+func (p *PMMail) maxMessageBytes() int64 {
+	if p.MaxMessageBytes > 0 {
+		return p.MaxMessageBytes
+	}
+	return DefaultMaxMessageBytes
+}
+
+// Returns the size of the message as Postmark
+// measures it: the bodies that are sent and
+// the base64 content of the attachments
+func (p *PMMail) messageBytes() int64 {
+	var total int64
+	if !p.usesTemplate() {
+		total += int64(len(p.HTMLBody) + len(p.TextBody))
+	}
+	for _, a := range p.attachments {
+		total += int64(len(a.Content))
+	}
+	return total
+}
+
+func (p *PMMail) maxAttachmentBytes() int64 {
+	if p.MaxAttachmentBytes > 0 {
+		return p.MaxAttachmentBytes
+	}
+	return DefaultMaxAttachmentBytes
+}
+
+// Checks that the attachments together stay
+// within the attachment size limit, and the
+// whole message within its size limit
+func (p *PMMail) checkAttachments() error {
+	var total int64
+	for _, a := range p.attachments {
+		total += a.size
+	}
+
+	if limit := p.maxAttachmentBytes(); total > limit {
+		return fmt.Errorf("Cannot send e-mail with %d bytes of attachments, the limit is %d (.MaxAttachmentBytes field)", total, limit)
+	}
+
+	if total, limit := p.messageBytes(), p.maxMessageBytes(); total > limit {
+		return fmt.Errorf("Cannot send e-mail of %d bytes, the limit is %d (.MaxMessageBytes field)", total, limit)
+	}
+
+	return nil
+}
@@ -0,0 +1,512 @@
+package postmark
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//go:embed testdata
+var testdata embed.FS
+
+func TestAddAttachmentFS(t *testing.T) {
+	p := testMail()
+	if err := p.AddAttachmentFS(testdata, "testdata/terms.txt"); err != nil {
+		t.Fatalf("Error attaching embedded file: %s", err)
+	}
+	if err := p.AddAttachmentFS(testdata, "testdata/logo.png"); err != nil {
+		t.Fatalf("Error attaching embedded file: %s", err)
+	}
+
+	if a := p.attachments[0]; a.Name != "terms.txt" || a.ContentType != "text/plain; charset=utf-8" || a.Content != base64.StdEncoding.EncodeToString([]byte("These are the terms.\n")) {
+		t.Errorf("Unexpected attachment %+v", a)
+	}
+	if a := p.attachments[1]; a.Name != "logo.png" || a.ContentType != "image/png" {
+		t.Errorf("Unexpected attachment %+v", a)
+	}
+
+	if err := p.AddAttachmentFS(testdata, "testdata/missing.txt"); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+	if err := p.AddAttachmentFS(testdata, "testdata"); err == nil {
+		t.Errorf("Expected an error for a directory")
+	}
+
+	p = testMail()
+	p.MaxAttachmentBytes = 4
+	if err := p.AddAttachmentFS(testdata, "testdata/terms.txt"); err == nil || len(p.attachments) != 0 {
+		t.Errorf("Expected an embedded file over the limit to be rejected")
+	}
+}
+
+func TestAddAttachmentFromReader(t *testing.T) {
+	p := testMail()
+	if err := p.AddAttachmentFromReader("invoice.pdf", strings.NewReader("%PDF-1.4"), "application/pdf"); err != nil {
+		t.Fatalf("Error attaching reader: %s", err)
+	}
+	if err := p.AddAttachmentFromReader("data.bin", strings.NewReader("data"), ""); err != nil {
+		t.Fatalf("Error attaching reader: %s", err)
+	}
+
+	if len(p.attachments) != 2 {
+		t.Fatalf("Expected 2 attachments, got %d", len(p.attachments))
+	}
+	a := p.attachments[0]
+	if a.Name != "invoice.pdf" || a.ContentType != "application/pdf" || a.Content != base64.StdEncoding.EncodeToString([]byte("%PDF-1.4")) {
+		t.Errorf("Unexpected attachment %+v", a)
+	}
+	if p.attachments[1].ContentType != "application/octet-stream" {
+		t.Errorf("Expected the default content type, got %q", p.attachments[1].ContentType)
+	}
+}
+
+func TestAddAttachmentFromReaderLimit(t *testing.T) {
+	p := testMail()
+	r := bytes.NewReader(make([]byte, DefaultMaxAttachmentBytes+1))
+	if err := p.AddAttachmentFromReader("big.bin", r, ""); err == nil {
+		t.Errorf("Expected an error for an attachment over the limit")
+	}
+	if len(p.attachments) != 0 {
+		t.Errorf("Expected the oversized attachment to be dropped")
+	}
+
+	// An endless stream, as a pipe or network
+	// reader might be, stops being read once it
+	// passes the limit
+	p.MaxAttachmentBytes = 1024
+	stream := &countingReader{}
+	if err := p.AddAttachmentFromReader("stream.bin", stream, ""); err == nil {
+		t.Errorf("Expected an error for an endless stream")
+	}
+	if stream.n > 64*1024 {
+		t.Errorf("Expected reading to stop near the limit, read %d bytes", stream.n)
+	}
+}
+
+// An endless reader of zeros that counts the
+// bytes read from it
+type countingReader struct {
+	n int
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	clear(b)
+	r.n += len(b)
+	return len(b), nil
+}
+
+func TestAddAttachmentFromBytes(t *testing.T) {
+	p := testMail()
+	if err := p.AddAttachmentFromBytes("report.csv", []byte("a,b\n1,2\n"), "text/csv"); err != nil {
+		t.Fatalf("Error attaching bytes: %s", err)
+	}
+	if err := p.AddAttachmentFromBytes("data.bin", []byte{0, 1}, ""); err != nil {
+		t.Fatalf("Error attaching bytes: %s", err)
+	}
+
+	a := p.attachments[0]
+	if a.Name != "report.csv" || a.ContentType != "text/csv" || a.Content != base64.StdEncoding.EncodeToString([]byte("a,b\n1,2\n")) {
+		t.Errorf("Unexpected attachment %+v", a)
+	}
+	if p.attachments[1].ContentType != "application/octet-stream" {
+		t.Errorf("Expected the default content type, got %q", p.attachments[1].ContentType)
+	}
+
+	if err := p.AddAttachmentFromBytes("big.bin", make([]byte, DefaultMaxAttachmentBytes+1), ""); err == nil {
+		t.Errorf("Expected an error for an attachment over the limit")
+	}
+}
+
+func TestAddAttachmentBytes(t *testing.T) {
+	p := testMail()
+	if err := p.AddAttachmentBytes("invoice.pdf", "", []byte("%PDF-1.7\n")); err != nil {
+		t.Fatalf("Error attaching bytes: %s", err)
+	}
+	if err := p.AddAttachmentBytes("export", "text/csv", []byte("a,b\n")); err != nil {
+		t.Fatalf("Error attaching bytes: %s", err)
+	}
+
+	if a := p.attachments[0]; a.Name != "invoice.pdf" || a.ContentType != "application/pdf" {
+		t.Errorf("Expected a detected PDF content type, got %+v", a)
+	}
+	if a := p.attachments[1]; a.Name != "export" || a.ContentType != "text/csv" {
+		t.Errorf("Expected the given content type, got %+v", a)
+	}
+
+	p.MaxAttachmentBytes = 4
+	if err := p.AddAttachmentBytes("big.bin", "", make([]byte, 5)); err == nil {
+		t.Errorf("Expected an error for an attachment over the limit")
+	}
+}
+
+func TestRemoveAttachment(t *testing.T) {
+	p := testMail()
+	for _, name := range []string{"a.txt", "b.txt", "a.txt"} {
+		p.AddAttachmentFromBytes(name, []byte(name), "text/plain")
+	}
+
+	if !p.RemoveAttachment("a.txt") {
+		t.Errorf("Expected a.txt to be removed")
+	}
+	if p.RemoveAttachment("c.txt") {
+		t.Errorf("Expected nothing to be removed for c.txt")
+	}
+	if len(p.attachments) != 2 || p.attachments[0].Name != "b.txt" || p.attachments[1].Name != "a.txt" {
+		t.Errorf("Expected only the first a.txt to be removed, got %+v", p.attachments)
+	}
+	if vacated := p.attachments[:3][2]; vacated.Content != "" {
+		t.Errorf("Expected the removed attachment's content to be released, got %+v", vacated)
+	}
+
+	p.ClearAttachments()
+	if len(p.attachments) != 0 || p.Attachments() != nil {
+		t.Errorf("Expected no attachments after ClearAttachments, got %d", len(p.attachments))
+	}
+	if packet, _ := p.MessageAsJSONPacket(); strings.Contains(string(packet), "Attachments") {
+		t.Errorf("Expected no Attachments in packet: %s", packet)
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	p := testMail()
+	p.AddAttachmentFromBytes("report.csv", []byte("a,b\n1,2\n"), "text/csv")
+	p.AddInlineAttachment("logo.png", "", "logo", []byte("\x89PNG\r\n\x1a\n"))
+
+	expected := []AttachmentInfo{
+		{Name: "report.csv", ContentType: "text/csv", Size: 8},
+		{Name: "logo.png", ContentType: "image/png", ContentID: "cid:logo", Size: 8},
+	}
+	infos := p.Attachments()
+	if !reflect.DeepEqual(infos, expected) {
+		t.Errorf("Expected attachments %+v, got %+v", expected, infos)
+	}
+
+	infos[0].Name = "changed.csv"
+	if p.attachments[0].Name != "report.csv" {
+		t.Errorf("Expected the summaries to be a copy")
+	}
+}
+
+func TestAttachmentTotalLimit(t *testing.T) {
+	p := testMail()
+	p.MaxAttachmentBytes = 10
+	if err := p.AddAttachmentFromBytes("a.bin", make([]byte, 6), ""); err != nil {
+		t.Fatalf("Error attaching bytes: %s", err)
+	}
+	if err := p.AddAttachmentFromBytes("b.bin", make([]byte, 6), ""); err != nil {
+		t.Fatalf("Error attaching bytes: %s", err)
+	}
+	if err := p.AddAttachmentFromBytes("c.bin", make([]byte, 11), ""); err == nil {
+		t.Errorf("Expected an error for a single attachment over the limit")
+	}
+
+	_, err := p.MessageAsJSONPacket()
+	if err == nil || !strings.Contains(err.Error(), "12 bytes") || !strings.Contains(err.Error(), "limit is 10") {
+		t.Errorf("Expected an error stating the total and the limit, got %v", err)
+	}
+
+	p.RemoveAttachment("b.bin")
+	if _, err := p.MessageAsJSONPacket(); err != nil {
+		t.Errorf("Unexpected error within the limit: %s", err)
+	}
+}
+
+func TestAddInlineAttachment(t *testing.T) {
+	recorder := NewRecorder()
+	c, _ := NewClient("1234567", WithHTTPClient(recorder.Client()))
+	p := c.NewMail()
+	p.Sender = "dave@flyclops.com"
+	p.To = "someone@example.com"
+	p.Subject = "Hello"
+	p.HTMLBody = `<img src="cid:logo">`
+	p.MaxAttachmentBytes = 16
+
+	if err := p.AddInlineAttachment("logo.png", "", "logo", []byte("\x89PNG\r\n\x1a\n")); err != nil {
+		t.Fatalf("Error attaching inline image: %s", err)
+	}
+	if err := p.AddInlineAttachmentFile("testdata/terms.txt", "cid:terms"); err == nil {
+		t.Errorf("Expected an inline file over the limit to be rejected")
+	}
+	if err := p.AddInlineAttachment("x.png", "image/png", "", nil); err == nil {
+		t.Errorf("Expected an error without a content ID")
+	}
+	if err := p.AddInlineAttachment("x.png", "image/png", "bad id", nil); err == nil {
+		t.Errorf("Expected an error for an invalid content ID")
+	}
+	if err := p.AddAttachmentBytes("notes.txt", "", make([]byte, 9)); err != nil {
+		t.Fatalf("Error attaching bytes: %s", err)
+	}
+
+	if _, err := p.Send(); err == nil || !strings.Contains(err.Error(), "17 bytes") {
+		t.Errorf("Expected inline attachments to count towards the limit, got %v", err)
+	}
+	p.RemoveAttachment("notes.txt")
+	if _, err := p.Send(); err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+
+	sent := recorder.Sent()
+	if len(sent) != 1 || len(sent[0].Attachments) != 1 {
+		t.Fatalf("Expected one message with one attachment, got %+v", sent)
+	}
+	if a := sent[0].Attachments[0]; a.ContentID != "cid:logo" || a.ContentType != "image/png" {
+		t.Errorf("Unexpected inline attachment %+v", a)
+	}
+
+	p = testMail()
+	p.AddAttachmentBytes("notes.txt", "", []byte("hello"))
+	if packet, _ := p.MessageAsJSONPacket(); strings.Contains(string(packet), "ContentID") {
+		t.Errorf("Expected no ContentID on a regular attachment: %s", packet)
+	}
+}
+
+func TestAddAttachmentFromURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/invoices/42.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.4"))
+		case "/report.csv":
+			w.Header()["Content-Type"] = nil
+			w.Write([]byte("a,b"))
+		case "/large":
+			w.Write(make([]byte, 100))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := testMail()
+	ctx := context.Background()
+	if err := p.AddAttachmentFromURLContext(ctx, server.URL+"/invoices/42.pdf?token=secret", ""); err != nil {
+		t.Fatalf("Error attaching from URL: %s", err)
+	}
+	if err := p.AddAttachmentFromURLContext(ctx, server.URL+"/report.csv", "report-2024.csv"); err != nil {
+		t.Fatalf("Error attaching from URL: %s", err)
+	}
+	if a := p.attachments[0]; a.Name != "42.pdf" || a.ContentType != "application/pdf" || a.size != 8 || a.Content != base64.StdEncoding.EncodeToString([]byte("%PDF-1.4")) {
+		t.Errorf("Unexpected attachment %+v", a)
+	}
+	if a := p.attachments[1]; a.Name != "report-2024.csv" || !strings.HasPrefix(a.ContentType, "text/csv") {
+		t.Errorf("Unexpected attachment %+v", a)
+	}
+
+	err := p.AddAttachmentFromURLContext(ctx, server.URL+"/missing?token=secret", "")
+	if err == nil || !strings.Contains(err.Error(), "404") || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected a 404 error without the query string, got %v", err)
+	}
+	if err := p.AddAttachmentFromURLContext(ctx, "ftp://example.com/a.txt", ""); err == nil {
+		t.Errorf("Expected an error for an ftp URL")
+	}
+	if err := p.AddAttachmentFromURLContext(ctx, server.URL+"/", ""); err == nil {
+		t.Errorf("Expected an error for a URL without a name")
+	}
+
+	p.MaxDownloadBytes = 50
+	if err := p.AddAttachmentFromURLContext(ctx, server.URL+"/large", ""); err == nil || !strings.Contains(err.Error(), "download limit") {
+		t.Errorf("Expected the download limit to be enforced, got %v", err)
+	}
+	p.MaxDownloadBytes = 0
+	p.MaxAttachmentBytes = 50
+	if err := p.AddAttachmentFromURLContext(ctx, server.URL+"/large", ""); err == nil || !strings.Contains(err.Error(), "attachment limit") {
+		t.Errorf("Expected the attachment limit to be enforced, got %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := p.AddAttachmentFromURLContext(cancelled, server.URL+"/report.csv", ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if len(p.attachments) != 2 {
+		t.Errorf("Expected failed downloads to attach nothing, got %d attachments", len(p.attachments))
+	}
+
+	if hc := testMail().downloadClient(); hc == http.DefaultClient || hc.Timeout != DefaultDownloadTimeout {
+		t.Errorf("Expected a download timeout in place of http.DefaultClient")
+	}
+
+	// Downloads go through the message's own
+	// HTTP client
+	var downloaded bool
+	hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		downloaded = true
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	c, _ := NewClient("1234567", WithHTTPClient(hc))
+	m := c.NewMail()
+	if err := m.AddAttachmentFromURL(server.URL+"/report.csv", ""); err != nil || !downloaded {
+		t.Errorf("Expected the download with the client's HTTP client, got %v", err)
+	}
+}
+
+func TestAddAttachments(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.csv", "b.csv", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatalf("Error writing file: %s", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "old.csv"), 0700); err != nil {
+		t.Fatalf("Error making directory: %s", err)
+	}
+
+	p := testMail()
+	if err := p.AddAttachmentsGlob(filepath.Join(dir, "*.csv")); err != nil {
+		t.Fatalf("Error attaching files: %s", err)
+	}
+	if len(p.attachments) != 2 || p.attachments[0].Name != "a.csv" || p.attachments[1].Name != "b.csv" {
+		t.Errorf("Unexpected attachments %+v", p.attachments)
+	}
+
+	if err := p.AddAttachmentsGlob(filepath.Join(dir, "*.pdf")); err == nil || !strings.Contains(err.Error(), "no files") {
+		t.Errorf("Expected an error for a pattern matching nothing, got %v", err)
+	}
+	if err := p.AddAttachmentsGlob(filepath.Join(dir, "old.*")); err == nil {
+		t.Errorf("Expected an error for a pattern matching only directories")
+	}
+	if err := p.AddAttachmentsGlob("["); err == nil {
+		t.Errorf("Expected an error for a malformed pattern")
+	}
+
+	p.ClearAttachments()
+	err := p.AddAttachments(filepath.Join(dir, "missing.txt"), filepath.Join(dir, "notes.txt"), filepath.Join(dir, "old.csv"))
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected a missing file error, got %v", err)
+	}
+	for _, name := range []string{"missing.txt", "old.csv"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected the error to name %s: %s", name, err)
+		}
+	}
+	if len(p.attachments) != 1 || p.attachments[0].Name != "notes.txt" {
+		t.Errorf("Expected the readable file to be attached, got %+v", p.attachments)
+	}
+}
+
+func TestMessageSizeLimit(t *testing.T) {
+	p := testMail()
+	p.MaxMessageBytes = 40
+	if err := p.AddAttachmentFromBytes("a.bin", make([]byte, 12), ""); err != nil {
+		t.Fatalf("Error attaching bytes: %s", err)
+	}
+
+	// 14 bytes of body and 16 of base64 leave
+	// too little room for another 16
+	err := p.AddAttachmentFromBytes("b.bin", make([]byte, 12), "")
+	if err == nil || !strings.Contains(err.Error(), "46 bytes") || !strings.Contains(err.Error(), "limit is 40") {
+		t.Errorf("Expected an error stating the total and the limit, got %v", err)
+	}
+	if err := p.AddAttachmentFromReader("b.bin", bytes.NewReader(make([]byte, 12)), ""); err == nil {
+		t.Errorf("Expected the limit to apply to streamed attachments")
+	}
+	if len(p.attachments) != 1 {
+		t.Fatalf("Expected the attachment over the limit to be left off, got %d", len(p.attachments))
+	}
+	if err := p.Validate(); err != nil {
+		t.Errorf("Unexpected error validating: %s", err)
+	}
+
+	p.HTMLBody = "<p>This is a test</p>"
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "51 bytes") || !strings.Contains(err.Error(), "MaxMessageBytes") {
+		t.Errorf("Expected the bodies to count towards the limit, got %v", err)
+	}
+
+	p.HTMLBody, p.TextBody = "", ""
+	p.TemplateAlias = "welcome"
+	p.MaxMessageBytes = 16
+	if err := p.Validate(); err != nil {
+		t.Errorf("Expected a template send's bodies not to count, got %v", err)
+	}
+
+	p = testMail()
+	p.AddAttachmentFromBytes("a.bin", make([]byte, 1024), "")
+	if err := p.Validate(); err != nil {
+		t.Errorf("Unexpected error with the default limit: %s", err)
+	}
+}
+
+func TestAddAttachmentFile(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	file := filepath.Join(t.TempDir(), "data.txt")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatalf("Error writing file: %s", err)
+	}
+
+	p := testMail()
+	if err := p.AddAttachment(file); err != nil {
+		t.Fatalf("Error attaching file: %s", err)
+	}
+	a := p.attachments[0]
+	if a.Name != "data.txt" || a.size != int64(len(data)) || a.Content != base64.StdEncoding.EncodeToString(data) {
+		t.Errorf("Unexpected attachment %s of %d bytes", a.Name, a.size)
+	}
+
+	p = testMail()
+	p.MaxAttachmentBytes = int64(len(data)) - 1
+	if err := p.AddAttachment(file); err == nil || len(p.attachments) != 0 {
+		t.Errorf("Expected a file over the limit to be rejected")
+	}
+
+	p = testMail()
+	if err := p.AddAttachmentAs(file, "digits.txt"); err != nil {
+		t.Fatalf("Error attaching file: %s", err)
+	}
+	if a := p.attachments[0]; a.Name != "digits.txt" || a.ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("Expected the file sent as digits.txt, got %s (%s)", a.Name, a.ContentType)
+	}
+}
+
+// Attaches a 9MB file, the size that used to
+// hold both the raw and encoded content at once.
+// Compare B/op with BenchmarkReadAllAttachment
+func BenchmarkAddAttachment(b *testing.B) {
+	file := benchmarkAttachment(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := testMail().AddAttachment(file); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The previous approach of reading the whole
+// file before encoding it, for comparison
+func BenchmarkReadAllAttachment(b *testing.B) {
+	file := benchmarkAttachment(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		testMail().addAttachment("data.bin", base64.StdEncoding.EncodeToString(content), int64(len(content)), "")
+	}
+}
+
+func benchmarkAttachment(b *testing.B) string {
+	file := filepath.Join(b.TempDir(), "data.bin")
+	if err := os.WriteFile(file, make([]byte, 9*1024*1024), 0600); err != nil {
+		b.Fatalf("Error writing file: %s", err)
+	}
+	return file
+}
+
+// An http.RoundTripper calling a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,247 @@
+package postmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const __POSTMARK_BATCH_PATH__ string = "/email/batch"
+const __POSTMARK_BATCH_TEMPLATE_PATH__ string = "/email/batchWithTemplates"
+
+// The maximum number of messages Postmark
+// accepts in a single batch request
+const BatchLimit int = 500
+
+// A set of messages delivered to Postmark
+// in a single request. The batch's client
+// is used for every message, regardless of
+// the client each PMMail was created with.
+// Each chunk's request is bounded by the
+// soonest SetTimeout of its messages
+type PMBatch struct {
+	client *Client
+
+	Messages []*PMMail
+
+	// The base URL of the Postmark API, defaulting
+	// to https://api.postmarkapp.com when empty.
+	// The messages' own Endpoint fields are ignored
+	Endpoint string
+
+	// The message stream used for any message that
+	// doesn't set its own MessageStream
+	MessageStream string
+
+	// Called after each chunk of up to BatchLimit
+	// messages has been submitted, with the range
+	// of Messages it covered and its outcome
+	OnChunk func(start, end int, replies []Reply, err error)
+}
+
+// The range of messages [Start, End) in a
+// chunk that could not be submitted
+type BatchChunkError struct {
+	Start int
+	End   int
+	Err   error
+}
+
+// Returned by PMBatch.Send when one or more
+// chunks could not be submitted. Replies for
+// the chunks that succeeded are still returned
+type BatchError struct {
+	Chunks []BatchChunkError
+}
+
+func (e *BatchError) Error() string {
+	parts := make([]string, len(e.Chunks))
+	for i, c := range e.Chunks {
+		parts[i] = fmt.Sprintf("messages %d-%d: %s", c.Start, c.End-1, c.Err)
+	}
+	return fmt.Sprintf("[Postmark] %d batch chunk(s) not submitted: %s", len(e.Chunks), strings.Join(parts, "; "))
+}
+
+// Exposes each chunk's error to errors.Is and errors.As
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Chunks))
+	for i, c := range e.Chunks {
+		errs[i] = c.Err
+	}
+	return errs
+}
+
+// Create a new PMBatch struct with
+// an Postmark API key, and return a
+// pointer to it. The batch is sent
+// with a default Client for the key
+func CreatePMBatch(apikey string) *PMBatch {
+	return &PMBatch{client: newClient(apikey)}
+}
+
+// Add one or more messages to the batch
+func (b *PMBatch) Add(messages ...*PMMail) {
+	b.Messages = append(b.Messages, messages...)
+}
+
+// Returns the compiled Postmark API
+// formatted JSON array of every message
+// in the batch
+func (b *PMBatch) MessagesAsJSONPacket() ([]byte, error) {
+	if len(b.Messages) > BatchLimit {
+		return []byte{}, fmt.Errorf("Batch of %d messages exceeds the %d message limit", len(b.Messages), BatchLimit)
+	}
+
+	c := b.sender()
+	return c.messagesAsJSONPacket(b.Messages, 0, false, b.MessageStream)
+}
+
+// Packs messages as the JSON array expected by
+// /email/batch, or as the {"Messages": [...]}
+// object expected by /email/batchWithTemplates.
+// Messages without a MessageStream are sent on
+// stream, or the client's default stream. A
+// message that can't be packed is reported by
+// its index in the batch, where messages begins
+// at start
+func (c *Client) messagesAsJSONPacket(messages []*PMMail, start int, templates bool, stream string) ([]byte, error) {
+	if len(messages) == 0 {
+		return []byte{}, fmt.Errorf("Cannot send an empty batch")
+	}
+
+	packets := make([]json.RawMessage, len(messages))
+	for i, m := range messages {
+		if m.usesTemplate() != templates {
+			if templates {
+				return []byte{}, fmt.Errorf("Message %d: Cannot send e-mail without a template in a template batch", start+i)
+			}
+			return []byte{}, fmt.Errorf("Message %d: Cannot send a templated e-mail in a batch, use SendWithTemplates", start+i)
+		}
+
+		packet, err := c.withMessageStream(m, stream).createJsonMessagePacket(c)
+		if err != nil {
+			return []byte{}, fmt.Errorf("Message %d: %w", start+i, err)
+		}
+		packets[i] = packet
+	}
+
+	if templates {
+		return marshalJSON(map[string]interface{}{"Messages": packets}, c.escapeHTML)
+	}
+
+	return marshalJSON(packets, c.escapeHTML)
+}
+
+// Attempts to send every message in the batch.
+// Messages are submitted in chunks of up to
+// BatchLimit, and Postmark accepts or rejects
+// each message individually, so the returned
+// replies are in the same order as Messages
+// and each must have its ErrorCode checked.
+// If a chunk cannot be submitted, its replies
+// are left empty, the remaining chunks are
+// still sent and a *BatchError is returned
+func (b *PMBatch) Send() ([]Reply, error) {
+	return b.SendContext(context.Background())
+}
+
+// Same as Send, but the requests are bound to ctx
+func (b *PMBatch) SendContext(ctx context.Context) ([]Reply, error) {
+	return b.send(ctx, false)
+}
+
+// Attempts to send every message in the batch
+// using the Postmark templates they name. Every
+// message must set TemplateID or TemplateAlias.
+// Chunking and replies work as they do for Send
+func (b *PMBatch) SendWithTemplates() ([]Reply, error) {
+	return b.SendWithTemplatesContext(context.Background())
+}
+
+// Same as SendWithTemplates, but the requests
+// are bound to ctx
+func (b *PMBatch) SendWithTemplatesContext(ctx context.Context) ([]Reply, error) {
+	return b.send(ctx, true)
+}
+
+func (b *PMBatch) send(ctx context.Context, templates bool) ([]Reply, error) {
+	if len(b.Messages) == 0 {
+		return nil, fmt.Errorf("Cannot send an empty batch")
+	}
+
+	c := b.sender()
+	replies := make([]Reply, len(b.Messages))
+	var failed []BatchChunkError
+
+	for start := 0; start < len(b.Messages); start += BatchLimit {
+		end := start + BatchLimit
+		if end > len(b.Messages) {
+			end = len(b.Messages)
+		}
+
+		chunk, err := b.sendChunk(ctx, c.forMessages(b.Messages[start:end]...), b.Messages[start:end], start, templates)
+		if err != nil {
+			failed = append(failed, BatchChunkError{Start: start, End: end, Err: err})
+		} else {
+			copy(replies[start:end], chunk)
+		}
+
+		if b.OnChunk != nil {
+			b.OnChunk(start, end, chunk, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return replies, &BatchError{Chunks: failed}
+	}
+
+	return replies, nil
+}
+
+// Returns the client the batch was created
+// with, pointed at Endpoint when it is set
+func (b *PMBatch) sender() *Client {
+	c := b.client
+	if c == nil {
+		c = newClient("")
+	}
+	if b.Endpoint != "" {
+		c = c.withEndpoint(b.Endpoint)
+	}
+	return c
+}
+
+func (b *PMBatch) sendChunk(ctx context.Context, c *Client, messages []*PMMail, start int, templates bool) ([]Reply, error) {
+	data, err := c.messagesAsJSONPacket(messages, start, templates, b.MessageStream)
+	if err != nil {
+		return nil, err
+	}
+
+	path := __POSTMARK_BATCH_PATH__
+	if templates {
+		path = __POSTMARK_BATCH_TEMPLATE_PATH__
+	}
+
+	// A chunk is sent with one token, chosen by
+	// the tag of its first message
+	var replies []Reply
+	err = c.withTokens(messages[0].Tag, func(c *Client, index int) error {
+		replies = nil
+		if err := c.withLogAttrs(slog.Int("messages", len(messages))).postJSON(ctx, path, data, &replies); err != nil {
+			return err
+		}
+		for i := range replies {
+			replies[i].Sandbox = c.Sandbox()
+			replies[i].TokenIndex = index
+		}
+		return nil
+	})
+	c.observeMessages(err, &replies)
+	if err != nil {
+		return nil, err
+	}
+
+	return replies, nil
+}
@@ -0,0 +1,223 @@
+package postmark
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPMBatchPacket(t *testing.T) {
+	b := CreatePMBatch("1234567")
+	first, second := testMail(), testMail()
+	second.To = "someone@example.com"
+	b.Add(first, second)
+
+	packet, err := b.MessagesAsJSONPacket()
+	if err != nil {
+		t.Fatalf("Trouble getting JSON packet: %s\n", err)
+	}
+
+	var messages []map[string]interface{}
+	if err := json.Unmarshal(packet, &messages); err != nil {
+		t.Fatalf("Batch packet is not a JSON array: %s\n", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if messages[1]["To"] != "someone@example.com" {
+		t.Errorf("Messages out of order: %v", messages)
+	}
+}
+
+func TestPMBatchLimits(t *testing.T) {
+	b := CreatePMBatch("1234567")
+	if _, err := b.Send(); err == nil {
+		t.Errorf("Expected an error sending an empty batch")
+	}
+
+	for i := 0; i <= BatchLimit; i++ {
+		b.Add(testMail())
+	}
+	if _, err := b.MessagesAsJSONPacket(); err == nil {
+		t.Errorf("Expected an error packing %d messages", len(b.Messages))
+	}
+}
+
+func TestPMBatchChunks(t *testing.T) {
+	b := CreatePMBatch("1234567")
+	for i := 0; i < BatchLimit*2+1; i++ {
+		b.Add(testMail())
+	}
+
+	var ranges [][2]int
+	b.OnChunk = func(start, end int, replies []Reply, err error) {
+		ranges = append(ranges, [2]int{start, end})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	replies, err := b.SendContext(ctx)
+	if len(replies) != len(b.Messages) {
+		t.Errorf("Expected %d replies, got %d", len(b.Messages), len(replies))
+	}
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a *BatchError, got %v", err)
+	}
+
+	expected := [][2]int{{0, 500}, {500, 1000}, {1000, 1001}}
+	if len(batchErr.Chunks) != len(expected) || len(ranges) != len(expected) {
+		t.Fatalf("Expected %d failed chunks and callbacks, got %v and %v", len(expected), batchErr.Chunks, ranges)
+	}
+	for i, r := range expected {
+		c := batchErr.Chunks[i]
+		if c.Start != r[0] || c.End != r[1] || ranges[i] != r {
+			t.Errorf("Chunk %d: expected range %v, got %d-%d (callback %v)", i, r, c.Start, c.End, ranges[i])
+		}
+		if !errors.Is(c.Err, context.Canceled) {
+			t.Errorf("Chunk %d: expected context.Canceled, got %v", i, c.Err)
+		}
+	}
+}
+
+func TestPMBatchInvalidMessage(t *testing.T) {
+	b := CreatePMBatch("1234567")
+	b.Add(testMail(), CreatePMMail("1234567"))
+
+	if _, err := b.MessagesAsJSONPacket(); err == nil {
+		t.Errorf("Expected an error for an incomplete message")
+	}
+
+	// Messages in later chunks are reported by
+	// their index in the whole batch
+	c, _ := NewClient("1234567", WithHTTPClient(NewRecorder().Client()))
+	b = c.NewBatch()
+	for i := 0; i < BatchLimit+3; i++ {
+		b.Add(testMail())
+	}
+	b.Messages[BatchLimit+2] = &PMMail{Sender: "dave@flyclops.com"}
+
+	_, err := b.Send()
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Chunks) != 1 {
+		t.Fatalf("Expected one failed chunk, got %v", err)
+	}
+	chunkErr := batchErr.Chunks[0].Err
+	if !strings.HasPrefix(chunkErr.Error(), fmt.Sprintf("Message %d: ", BatchLimit+2)) || errors.Unwrap(chunkErr) == nil {
+		t.Errorf("Expected the message's batch index wrapping its error, got %v", chunkErr)
+	}
+}
+
+func TestPMBatchTemplatePacket(t *testing.T) {
+	first, second := testMail(), testMail()
+	first.TemplateAlias = "welcome"
+	second.TemplateID = 1234
+	second.TemplateModel = map[string]interface{}{"name": "Dave"}
+
+	packet, err := newClient("1234567").messagesAsJSONPacket([]*PMMail{first, second}, 0, true, "")
+	if err != nil {
+		t.Fatalf("Trouble getting JSON packet: %s\n", err)
+	}
+
+	var payload struct {
+		Messages []map[string]interface{}
+	}
+	if err := json.Unmarshal(packet, &payload); err != nil {
+		t.Fatalf("Template batch packet is not a JSON object: %s\n", err)
+	}
+	if len(payload.Messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(payload.Messages))
+	}
+	if payload.Messages[0]["TemplateAlias"] != "welcome" || payload.Messages[1]["TemplateId"] != float64(1234) {
+		t.Errorf("Template fields missing from packet: %s", packet)
+	}
+}
+
+func TestPMBatchTemplateMismatch(t *testing.T) {
+	templated := testMail()
+	templated.TemplateAlias = "welcome"
+
+	if _, err := newClient("1234567").messagesAsJSONPacket([]*PMMail{testMail(), templated}, 0, false, ""); err == nil {
+		t.Errorf("Expected an error for a templated message in a plain batch")
+	}
+	if _, err := newClient("1234567").messagesAsJSONPacket([]*PMMail{templated, testMail()}, 0, true, ""); err == nil {
+		t.Errorf("Expected an error for a plain message in a template batch")
+	}
+}
+
+func TestPMBatchMessageStream(t *testing.T) {
+	b := CreatePMBatch("1234567")
+	b.MessageStream = "broadcast"
+	first, second := testMail(), testMail()
+	second.MessageStream = "outbound"
+	b.Add(first, second)
+
+	packet, err := b.MessagesAsJSONPacket()
+	if err != nil {
+		t.Fatalf("Trouble getting JSON packet: %s\n", err)
+	}
+
+	var messages []map[string]interface{}
+	if err := json.Unmarshal(packet, &messages); err != nil {
+		t.Fatalf("Batch packet is not a JSON array: %s\n", err)
+	}
+	if messages[0]["MessageStream"] != "broadcast" || messages[1]["MessageStream"] != "outbound" {
+		t.Errorf("Expected the batch stream only as a default, got %s", packet)
+	}
+	if first.MessageStream != "" {
+		t.Errorf("Expected the batch default to leave the message untouched, got %q", first.MessageStream)
+	}
+}
+
+func TestPMBatchEndpoint(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+
+		var messages []map[string]interface{}
+		body, _ := readRequestBody(r)
+		json.Unmarshal(body, &messages)
+		if len(paths) == 2 {
+			w.WriteHeader(500)
+			return
+		}
+
+		replies := make([]Reply, len(messages))
+		for i := range replies {
+			replies[i].MessageID = fmt.Sprintf("%d-%d", len(paths), i)
+		}
+		json.NewEncoder(w).Encode(replies)
+	}))
+	defer server.Close()
+
+	b := CreatePMBatch("1234567")
+	b.Endpoint = server.URL
+	for i := 0; i < BatchLimit*2+1; i++ {
+		b.Add(testMail())
+	}
+
+	replies, err := b.Send()
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Chunks) != 1 {
+		t.Fatalf("Expected one failed chunk, got %v", err)
+	}
+	if c := batchErr.Chunks[0]; c.Start != BatchLimit || c.End != BatchLimit*2 {
+		t.Errorf("Expected the second chunk to fail, got %d-%d", c.Start, c.End)
+	}
+	if replies[0].MessageID != "1-0" || replies[BatchLimit].MessageID != "" || replies[BatchLimit*2].MessageID != "3-0" {
+		t.Errorf("Expected replies from the successful chunks in input order")
+	}
+	for _, path := range paths {
+		if path != "/email/batch" {
+			t.Errorf("Expected requests to /email/batch, got %q", path)
+		}
+	}
+}
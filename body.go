@@ -0,0 +1,55 @@
+package postmark
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Postmark's limit on the size of each of a
+// message's HTML and text bodies, 5MB
+const MaxBodyBytes int64 = 5 * 1024 * 1024
+
+// Set the HTML body to the contents of a file,
+// such as a template rendered to disk
+func (p *PMMail) SetHTMLBodyFromFile(file string) error {
+	body, err := readBodyFile(file)
+	if err != nil {
+		return err
+	}
+	p.HTMLBody = body
+	return nil
+}
+
+// Set the text body to the contents of a file
+func (p *PMMail) SetTextBodyFromFile(file string) error {
+	body, err := readBodyFile(file)
+	if err != nil {
+		return err
+	}
+	p.TextBody = body
+	return nil
+}
+
+// Reads a body from a file, refusing empty files
+// and those over MaxBodyBytes
+func readBodyFile(file string) (string, error) {
+	fileHandle, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer fileHandle.Close()
+
+	content, err := io.ReadAll(io.LimitReader(fileHandle, MaxBodyBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(content)) > MaxBodyBytes {
+		return "", fmt.Errorf("File %s exceeds the %d byte body limit.", file, MaxBodyBytes)
+	}
+	if len(content) == 0 {
+		return "", fmt.Errorf("Cannot use empty file %s as a body", file)
+	}
+
+	return string(content), nil
+}
@@ -0,0 +1,34 @@
+package postmark
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetBodyFromFile(t *testing.T) {
+	dir := t.TempDir()
+	html := filepath.Join(dir, "body.html")
+	text := filepath.Join(dir, "body.txt")
+	empty := filepath.Join(dir, "empty.txt")
+	os.WriteFile(html, []byte("<strong>Hello</strong>"), 0600)
+	os.WriteFile(text, []byte("Hello"), 0600)
+	os.WriteFile(empty, nil, 0600)
+
+	p := testMail()
+	if err := p.SetHTMLBodyFromFile(html); err != nil || p.HTMLBody != "<strong>Hello</strong>" {
+		t.Errorf("Expected the HTML body from the file, got %q and %v", p.HTMLBody, err)
+	}
+	if err := p.SetTextBodyFromFile(text); err != nil || p.TextBody != "Hello" {
+		t.Errorf("Expected the text body from the file, got %q and %v", p.TextBody, err)
+	}
+
+	if err := p.SetTextBodyFromFile(empty); err == nil || p.TextBody != "Hello" {
+		t.Errorf("Expected an error for an empty file, got %v", err)
+	}
+	if err := p.SetHTMLBodyFromFile(filepath.Join(dir, "missing.html")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the os error for a missing file, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package postmark
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const __POSTMARK_DELIVERY_STATS_PATH__ string = "/deliverystats"
+const __POSTMARK_BOUNCES_PATH__ string = "/bounces"
+
+// A server's delivery health, as returned by
+// Client.DeliveryStats
+type DeliveryStats struct {
+	// How many recipients are inactive, and so
+	// won't be sent to, because of bounces or
+	// spam complaints
+	InactiveMails int
+
+	// The server's bounce counts by type. The
+	// first, named "All", is their total
+	Bounces []BounceType
+}
+
+// The number of bounces of one type, such as
+// "HardBounce"
+type BounceType struct {
+	Type  string
+	Name  string
+	Count int
+}
+
+// Returns the bounce counts and inactive
+// recipient total of the client's server
+func (c *Client) DeliveryStats() (*DeliveryStats, error) {
+	return c.DeliveryStatsContext(context.Background())
+}
+
+// Same as DeliveryStats, but the request is
+// bound to ctx
+func (c *Client) DeliveryStatsContext(ctx context.Context) (*DeliveryStats, error) {
+	stats := &DeliveryStats{}
+	if err := c.getJSON(ctx, __POSTMARK_DELIVERY_STATS_PATH__, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// The most bounces Postmark returns in one page
+const MaxBounceCount int = 500
+
+// One bounce, as listed by Client.ListBounces
+type Bounce struct {
+	ID            int64
+	Type          string
+	TypeCode      int
+	Name          string
+	Tag           string
+	MessageID     string
+	MessageStream string
+	ServerID      int64
+	Description   string
+	Details       string
+	Email         string
+	From          string
+	Subject       string
+	BouncedAt     time.Time
+	DumpAvailable bool
+
+	// Whether the bounce made Postmark stop
+	// sending to Email, and whether it can be
+	// reactivated
+	Inactive    bool
+	CanActivate bool
+}
+
+// One page of bounces, as returned by
+// Client.ListBounces
+type BounceList struct {
+	// How many bounces match the query across
+	// every page
+	TotalCount int
+	Bounces    []Bounce
+}
+
+// Filters and pages the bounces listed by
+// Client.ListBounces. Empty filters match
+// every bounce
+type BounceQuery struct {
+	// How many bounces to return, up to
+	// MaxBounceCount, which is used when zero,
+	// after skipping Offset of them
+	Count  int
+	Offset int
+
+	// A bounce type, such as "HardBounce"
+	Type string
+
+	// Filters by whether the bounce deactivated
+	// its address. Nil matches both
+	Inactive *bool
+
+	// Matches bounces whose address contains
+	// EmailFilter
+	EmailFilter   string
+	Tag           string
+	MessageID     string
+	MessageStream string
+
+	// Limits bounces to those between the two
+	// times, when they are set
+	FromDate time.Time
+	ToDate   time.Time
+}
+
+// Returns the query string for q
+func (q BounceQuery) values() (url.Values, error) {
+	count := q.Count
+	if count == 0 {
+		count = MaxBounceCount
+	}
+	if count < 0 || count > MaxBounceCount {
+		return nil, fmt.Errorf("Cannot list %d bounces, the limit is %d (.Count field)", count, MaxBounceCount)
+	}
+	if q.Offset < 0 {
+		return nil, fmt.Errorf("Cannot list bounces from a negative offset (.Offset field)")
+	}
+
+	values := url.Values{}
+	values.Set("count", strconv.Itoa(count))
+	values.Set("offset", strconv.Itoa(q.Offset))
+	if q.Type != "" {
+		values.Set("type", q.Type)
+	}
+	if q.Inactive != nil {
+		values.Set("inactive", strconv.FormatBool(*q.Inactive))
+	}
+	if q.EmailFilter != "" {
+		values.Set("emailFilter", q.EmailFilter)
+	}
+	if q.Tag != "" {
+		values.Set("tag", q.Tag)
+	}
+	if q.MessageID != "" {
+		values.Set("messageID", q.MessageID)
+	}
+	if q.MessageStream != "" {
+		values.Set("messagestream", q.MessageStream)
+	}
+	if !q.FromDate.IsZero() {
+		values.Set("fromdate", q.FromDate.Format(time.RFC3339))
+	}
+	if !q.ToDate.IsZero() {
+		values.Set("todate", q.ToDate.Format(time.RFC3339))
+	}
+	return values, nil
+}
+
+// Returns a page of the bounces on the client's
+// server matching query, most recent first
+func (c *Client) ListBounces(query BounceQuery) (*BounceList, error) {
+	return c.ListBouncesContext(context.Background(), query)
+}
+
+// Same as ListBounces, but the request is bound
+// to ctx
+func (c *Client) ListBouncesContext(ctx context.Context, query BounceQuery) (*BounceList, error) {
+	values, err := query.values()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &BounceList{}
+	if err := c.getJSON(ctx, __POSTMARK_BOUNCES_PATH__+"?"+values.Encode(), list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Reactivates the address of a bounce, such as
+// once its owner has fixed their mailbox, so
+// Postmark sends to it again. Only bounces
+// whose CanActivate is set can be reactivated
+func (c *Client) ActivateBounce(bounceID int64) (*Reply, error) {
+	return c.ActivateBounceContext(context.Background(), bounceID)
+}
+
+// Same as ActivateBounce, but the request is
+// bound to ctx
+func (c *Client) ActivateBounceContext(ctx context.Context, bounceID int64) (*Reply, error) {
+	reply := &Reply{}
+	path := fmt.Sprintf("%s/%d/activate", __POSTMARK_BOUNCES_PATH__, bounceID)
+	if err := c.requestJSON(ctx, "PUT", path, nil, reply); err != nil {
+		reply.setError(err)
+		return reply, err
+	}
+	return reply, reply.Err()
+}
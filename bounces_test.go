@@ -0,0 +1,134 @@
+package postmark
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeliveryStats(t *testing.T) {
+	var method, path, token, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		token = r.Header.Get("X-Postmark-Server-Token")
+		contentType = r.Header.Get("Content-Type")
+		w.Write([]byte(`{
+			"InactiveMails": 192,
+			"Bounces": [
+				{"Name": "All", "Count": 253},
+				{"Type": "HardBounce", "Name": "Hard bounce", "Count": 195}
+			]
+		}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	stats, err := c.DeliveryStats()
+	if err != nil {
+		t.Fatalf("Unexpected error getting delivery stats: %s", err)
+	}
+	if method != "GET" || path != "/deliverystats" || token != "1234567" || contentType != "" {
+		t.Errorf("Unexpected request %s %s with token %q and Content-Type %q", method, path, token, contentType)
+	}
+	if stats.InactiveMails != 192 || len(stats.Bounces) != 2 || stats.Bounces[1] != (BounceType{"HardBounce", "Hard bounce", 195}) {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	c, _ = NewClient("bad", WithEndpoint(server.URL))
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"ErrorCode": 10, "Message": "Bad or missing API token"}`))
+	})
+	if stats, err := c.DeliveryStats(); err == nil || stats != nil || !IsErrorCode(err, ErrCodeBadAPIToken) {
+		t.Errorf("Expected a bad token error, got %+v and %v", stats, err)
+	}
+}
+
+func TestListBounces(t *testing.T) {
+	var method, path string
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, query = r.Method, r.URL.Path, r.URL.Query()
+		w.Write([]byte(`{
+			"TotalCount": 253,
+			"Bounces": [{
+				"ID": 692560173,
+				"Type": "HardBounce",
+				"TypeCode": 1,
+				"Email": "anything@blackhole.postmarkapp.com",
+				"BouncedAt": "2019-11-05T16:33:54.9070259Z",
+				"Inactive": true,
+				"CanActivate": true
+			}]
+		}`))
+	}))
+	defer server.Close()
+
+	var events []SendEvent
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithOnSend(func(event SendEvent) {
+		events = append(events, event)
+	}))
+	list, err := c.ListBounces(BounceQuery{Count: 10, Offset: 20, Type: "HardBounce", Inactive: Bool(true), EmailFilter: "blackhole"})
+	if err != nil {
+		t.Fatalf("Unexpected error listing bounces: %s", err)
+	}
+	if method != "GET" || path != "/bounces" {
+		t.Errorf("Unexpected request %s %s", method, path)
+	}
+	expected := url.Values{"count": {"10"}, "offset": {"20"}, "type": {"HardBounce"}, "inactive": {"true"}, "emailFilter": {"blackhole"}}
+	if !reflect.DeepEqual(query, expected) {
+		t.Errorf("Expected query %v, got %v", expected, query)
+	}
+	if len(events) != 1 || events[0].Path != "/bounces" {
+		t.Errorf("Expected an event without the query string, got %+v", events)
+	}
+
+	if list.TotalCount != 253 || len(list.Bounces) != 1 {
+		t.Fatalf("Unexpected list %+v", list)
+	}
+	bounce := list.Bounces[0]
+	if bounce.ID != 692560173 || bounce.Type != "HardBounce" || !bounce.Inactive || !bounce.CanActivate || bounce.BouncedAt.Year() != 2019 {
+		t.Errorf("Unexpected bounce %+v", bounce)
+	}
+
+	if _, err := c.ListBounces(BounceQuery{}); err != nil || query.Get("count") != "500" || query.Get("offset") != "0" {
+		t.Errorf("Expected the default page, got %v and %v", query, err)
+	}
+	if _, err := c.ListBounces(BounceQuery{Count: 501}); err == nil || !strings.Contains(err.Error(), ".Count field") {
+		t.Errorf("Expected a count limit error, got %v", err)
+	}
+	if _, err := c.ListBounces(BounceQuery{Offset: -1}); err == nil {
+		t.Errorf("Expected an error for a negative offset")
+	}
+}
+
+func TestActivateBounce(t *testing.T) {
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		if path == "/bounces/1/activate" {
+			w.WriteHeader(422)
+			w.Write([]byte(`{"ErrorCode": 701, "Message": "This bounce cannot be activated."}`))
+			return
+		}
+		w.Write([]byte(`{"Message": "OK", "Bounce": {"ID": 692560173, "Inactive": false}}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	reply, err := c.ActivateBounce(692560173)
+	if err != nil {
+		t.Fatalf("Unexpected error activating bounce: %s", err)
+	}
+	if method != "PUT" || path != "/bounces/692560173/activate" || reply.Message != "OK" || reply.StatusCode != 200 {
+		t.Errorf("Unexpected reply %+v to %s %s", reply, method, path)
+	}
+
+	reply, err = c.ActivateBounce(1)
+	if !IsErrorCode(err, 701) || reply == nil || reply.ErrorCode != 701 || reply.StatusCode != 422 {
+		t.Errorf("Expected Postmark's error with the reply, got %+v and %v", reply, err)
+	}
+}
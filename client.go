@@ -0,0 +1,811 @@
+package postmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// The headers Postmark reads server and
+// account tokens from
+const __SERVER_TOKEN_HEADER__ string = "X-Postmark-Server-Token"
+const __ACCOUNT_TOKEN_HEADER__ string = "X-Postmark-Account-Token"
+
+// The most of a response body that is read;
+// even a full batch reply is far smaller
+const __MAX_RESPONSE_SIZE__ int = 4 << 20
+
+// A Client sends messages to Postmark with one
+// server token and HTTP client, so connections
+// are reused across sends. Once created, a
+// Client is safe to share between goroutines
+type Client struct {
+	apiKey           string
+	tokenHeader      string
+	userAgent        string
+	httpClient       *http.Client
+	endpoint         string
+	messageStream    string
+	retries          int
+	retryDelay       time.Duration
+	timeout          time.Duration
+	limiter          *RateLimiter
+	debugFunc        func(DebugEvent)
+	metrics          Metrics
+	logger           *slog.Logger
+	markdown         MarkdownRenderer
+	tokens           []string
+	nextToken        *uint32
+	tokenSelector    TokenSelector
+	tokenFailover    bool
+	compressAbove    int
+	escapeHTML       bool
+	skipAddressCheck bool
+	onSend           func(SendEvent)
+	requestHeaders   http.Header
+
+	// Where a hooked send records the status of
+	// each attempt's response
+	lastStatus *int
+}
+
+// Configures a Client created by NewClient
+type Option func(*Client) error
+
+// Create a new Client with a Postmark
+// server token and any options, and
+// return a pointer to it. The token and
+// options are checked here, so a
+// misconfigured client is never returned
+func NewClient(apikey string, opts ...Option) (*Client, error) {
+	if strings.TrimSpace(apikey) == "" {
+		return nil, fmt.Errorf("Cannot create a client without a server token")
+	}
+
+	c := newClient(apikey)
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// The environment variables NewClientFromEnv
+// and NewAccountClientFromEnv read tokens from
+const (
+	ServerTokenEnv  string = "POSTMARK_SERVER_TOKEN"
+	AccountTokenEnv string = "POSTMARK_ACCOUNT_TOKEN"
+)
+
+// Create a new Client as NewClient does, with
+// the server token in POSTMARK_SERVER_TOKEN,
+// trimmed of surrounding whitespace
+func NewClientFromEnv(opts ...Option) (*Client, error) {
+	token := strings.TrimSpace(os.Getenv(ServerTokenEnv))
+	if token == "" {
+		return nil, fmt.Errorf("Cannot create a client without a server token (%s environment variable)", ServerTokenEnv)
+	}
+
+	return NewClient(token, opts...)
+}
+
+func newClient(apikey string) *Client {
+	return &Client{
+		apiKey:        apikey,
+		tokenHeader:   __SERVER_TOKEN_HEADER__,
+		userAgent:     defaultUserAgent(),
+		httpClient:    http.DefaultClient,
+		compressAbove: DefaultCompressionThreshold,
+	}
+}
+
+func defaultUserAgent() string {
+	return fmt.Sprintf("Go (Go postmark package library version %s)", __VERSION__)
+}
+
+// The server token Postmark accepts for test
+// sends, which are validated but not delivered
+const SandboxToken string = "POSTMARK_API_TEST"
+
+// Create a new sandbox Client, as NewClient
+// does with WithSandbox, and return a pointer
+// to it
+func NewSandboxClient(opts ...Option) (*Client, error) {
+	return NewClient(SandboxToken, append([]Option{WithSandbox()}, opts...)...)
+}
+
+// Sends with SandboxToken instead of the
+// client's server token, so Postmark validates
+// messages without delivering them. Validation
+// errors are returned as usual, and every
+// Reply has Sandbox set. It can't be combined
+// with WithTokens
+func WithSandbox() Option {
+	return func(c *Client) error {
+		if c.tokens != nil {
+			return fmt.Errorf("Cannot create a sandbox client with several server tokens (WithTokens and WithSandbox options)")
+		}
+		c.apiKey = SandboxToken
+		return nil
+	}
+}
+
+// Reports whether the client sends with
+// SandboxToken, see WithSandbox
+func (c *Client) Sandbox() bool {
+	return c.apiKey == SandboxToken
+}
+
+// Sends requests with hc instead of
+// http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) error {
+		if hc == nil {
+			return fmt.Errorf("Cannot create a client with a nil HTTP client")
+		}
+		c.httpClient = hc
+		return nil
+	}
+}
+
+// Sends requests to the Postmark API at the
+// given base URL instead of the production API
+func WithEndpoint(endpoint string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("Cannot create a client with endpoint %q, it must be an absolute URL", endpoint)
+		}
+		c.endpoint = endpoint
+		return nil
+	}
+}
+
+// Replaces the User-Agent header sent
+// with every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) error {
+		if userAgent == "" {
+			return fmt.Errorf("Cannot create a client with an empty user agent")
+		}
+		c.userAgent = userAgent
+		return nil
+	}
+}
+
+// The HTTP headers the client sets itself,
+// which WithRequestHeader can't replace
+var reservedRequestHeaders = []string{"Accept", "Content-Type", "Content-Encoding", "Content-Length", "User-Agent", __SERVER_TOKEN_HEADER__, __ACCOUNT_TOKEN_HEADER__}
+
+// Sets an HTTP header on every request the
+// client makes to the Postmark API, such as for
+// a proxy in front of it. This is unrelated to
+// a message's own e-mail headers, which are
+// only sent in its JSON. The headers the client
+// sets itself, such as Content-Type and the
+// token header, can't be set this way. The
+// value is redacted from WithDebugFunc events
+func WithRequestHeader(name, value string) Option {
+	return func(c *Client) error {
+		for _, reserved := range reservedRequestHeaders {
+			if strings.EqualFold(name, reserved) {
+				return fmt.Errorf("Cannot create a client that replaces the %s header (WithRequestHeader option)", reserved)
+			}
+		}
+		if c.requestHeaders == nil {
+			c.requestHeaders = make(http.Header)
+		}
+		c.requestHeaders.Add(name, value)
+		return nil
+	}
+}
+
+// Sends every message that doesn't set its own
+// MessageStream through the given stream
+func WithMessageStream(stream string) Option {
+	return func(c *Client) error {
+		c.messageStream = stream
+		return nil
+	}
+}
+
+// Retries requests that fail with a network
+// error or an HTTP 5xx status up to max times,
+// waiting base, then twice as long, and so on,
+// with some jitter. Rate limited requests are
+// retried after the wait Postmark asks for, if
+// any. Requests rejected as unauthorized or
+// invalid are never retried, and no retry is
+// made past the context deadline
+func WithRetries(max int, base time.Duration) Option {
+	return func(c *Client) error {
+		if max < 0 {
+			return fmt.Errorf("Cannot create a client with %d retries", max)
+		}
+		if base < 0 {
+			return fmt.Errorf("Cannot create a client with a negative retry delay")
+		}
+		c.retries = max
+		c.retryDelay = base
+		return nil
+	}
+}
+
+// Bounds each API call, including any retries,
+// to d even when the context has no deadline.
+// Whichever of d and the context's deadline is
+// sooner applies, and running out of d returns
+// an error matching both ErrTimeout and
+// context.DeadlineExceeded. Zero means no limit
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("Cannot create a client with a negative timeout")
+		}
+		c.timeout = d
+		return nil
+	}
+}
+
+// Escapes <, > and & in the JSON sent to
+// Postmark as \u003c style sequences, as
+// json.Marshal does. By default they are sent
+// as they are, which keeps HTML bodies small
+// and readable; this is only for code that
+// depends on the old escaped packets
+func WithHTMLEscaping() Option {
+	return func(c *Client) error {
+		c.escapeHTML = true
+		return nil
+	}
+}
+
+// Sends messages without checking that their
+// addresses parse as RFC 5322 mailboxes, for
+// unusual addresses Postmark accepts anyway.
+// Recipients are still counted against
+// MaxRecipients
+func WithoutAddressValidation() Option {
+	return func(c *Client) error {
+		c.skipAddressCheck = true
+		return nil
+	}
+}
+
+// Limits every request made by the client,
+// including batches and retries, to a sustained
+// requestsPerSecond with bursts of up to burst
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(c *Client) error {
+		limiter, err := NewRateLimiter(requestsPerSecond, burst)
+		if err != nil {
+			return err
+		}
+		c.limiter = limiter
+		return nil
+	}
+}
+
+// Limits every request made by the client with
+// limiter, which may be shared with other clients
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) error {
+		if limiter == nil {
+			return fmt.Errorf("Cannot create a client with a nil rate limiter")
+		}
+		c.limiter = limiter
+		return nil
+	}
+}
+
+// Returns the client's rate limiter, or nil
+// when its requests aren't rate limited
+func (c *Client) RateLimiter() *RateLimiter {
+	return c.limiter
+}
+
+// Create a new PMMail that is sent with
+// this client, and return a pointer to it
+func (c *Client) NewMail() *PMMail {
+	return &PMMail{client: c}
+}
+
+// Create a new PMBatch that is sent with
+// this client, and return a pointer to it
+func (c *Client) NewBatch() *PMBatch {
+	return &PMBatch{client: c}
+}
+
+// Attempts to send the email with this client
+func (c *Client) Send(m *PMMail) (*Reply, error) {
+	return c.SendContext(context.Background(), m)
+}
+
+// Same as Send, but the request is bound to ctx
+func (c *Client) SendContext(ctx context.Context, m *PMMail) (*Reply, error) {
+	if m.usesTemplate() {
+		return nil, fmt.Errorf("Cannot send a templated e-mail with Send, use SendWithTemplate")
+	}
+
+	return c.send(ctx, __POSTMARK_EMAIL_PATH__, m)
+}
+
+// Attempts to send the email with this client,
+// using the Postmark template it names
+func (c *Client) SendWithTemplate(m *PMMail) (*Reply, error) {
+	return c.SendWithTemplateContext(context.Background(), m)
+}
+
+// Same as SendWithTemplate, but the request is
+// bound to ctx
+func (c *Client) SendWithTemplateContext(ctx context.Context, m *PMMail) (*Reply, error) {
+	if !m.usesTemplate() {
+		return nil, fmt.Errorf("Cannot send e-mail with a template without a template ID or alias (.TemplateID or .TemplateAlias field)")
+	}
+
+	return c.send(ctx, __POSTMARK_TEMPLATE_PATH__, m)
+}
+
+// Attempts to send the messages as a batch with
+// this client. See PMBatch.Send
+func (c *Client) SendBatch(messages []*PMMail) ([]Reply, error) {
+	return c.SendBatchContext(context.Background(), messages)
+}
+
+// Same as SendBatch, but the requests are bound to ctx
+func (c *Client) SendBatchContext(ctx context.Context, messages []*PMMail) ([]Reply, error) {
+	b := c.NewBatch()
+	b.Add(messages...)
+	return b.SendContext(ctx)
+}
+
+// Attempts to send the messages as a template
+// batch with this client. See PMBatch.SendWithTemplates
+func (c *Client) SendBatchWithTemplates(messages []*PMMail) ([]Reply, error) {
+	return c.SendBatchWithTemplatesContext(context.Background(), messages)
+}
+
+// Same as SendBatchWithTemplates, but the requests
+// are bound to ctx
+func (c *Client) SendBatchWithTemplatesContext(ctx context.Context, messages []*PMMail) ([]Reply, error) {
+	b := c.NewBatch()
+	b.Add(messages...)
+	return b.SendWithTemplatesContext(ctx)
+}
+
+// Returns a copy of the client that sends with
+// another server token, for sending on behalf
+// of several Postmark servers. The copy shares
+// the HTTP client, and so its connections,
+// and every other option but WithTokens with c
+func (c *Client) WithToken(token string) (*Client, error) {
+	if strings.TrimSpace(token) == "" {
+		return nil, fmt.Errorf("Cannot create a client without a server token")
+	}
+
+	derived := *c
+	derived.apiKey = token
+	derived.tokens = nil
+	return &derived, nil
+}
+
+// Returns a copy of the client that sends
+// requests to endpoint
+func (c *Client) withEndpoint(endpoint string) *Client {
+	derived := *c
+	derived.endpoint = endpoint
+	return &derived
+}
+
+// Returns a copy of the client with the given
+// retry policy
+func (c *Client) withRetries(max int, base time.Duration) *Client {
+	derived := *c
+	derived.retries = max
+	derived.retryDelay = base
+	return &derived
+}
+
+// Returns a copy of the client with calls
+// bounded by d, unless its own timeout is
+// already sooner
+func (c *Client) withTimeout(d time.Duration) *Client {
+	derived := *c
+	if derived.timeout <= 0 || d < derived.timeout {
+		derived.timeout = d
+	}
+	return &derived
+}
+
+// Returns the client bounded by the soonest
+// SetTimeout timeout of the messages sent in
+// one request, and retrying with the
+// SetRetryPolicy policy allowing the fewest
+// retries, if any of them set one
+func (c *Client) forMessages(messages ...*PMMail) *Client {
+	var timeout time.Duration
+	var policy *retryPolicy
+	for _, m := range messages {
+		if m.timeout > 0 && (timeout == 0 || m.timeout < timeout) {
+			timeout = m.timeout
+		}
+		if m.retryPolicy != nil && (policy == nil || m.retryPolicy.maxRetries < policy.maxRetries) {
+			policy = m.retryPolicy
+		}
+	}
+	if timeout > 0 {
+		c = c.withTimeout(timeout)
+	}
+	if policy != nil {
+		c = c.withRetries(policy.maxRetries, policy.baseDelay)
+	}
+	return c
+}
+
+// Returns m, or a copy of it using the client's
+// default message stream if m doesn't set one
+func (c *Client) withMessageStream(m *PMMail, stream string) *PMMail {
+	if stream == "" {
+		stream = c.messageStream
+	}
+	if m.MessageStream != "" || stream == "" {
+		return m
+	}
+
+	withStream := *m
+	withStream.MessageStream = stream
+	return &withStream
+}
+
+func (c *Client) send(ctx context.Context, path string, m *PMMail) (*Reply, error) {
+	c = c.forMessages(m)
+
+	data, err := c.withMessageStream(m, "").createJsonMessagePacket(c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var reply *Reply
+	err = c.withTokens(m.Tag, func(c *Client, index int) error {
+		var sendErr error
+		reply, sendErr = c.postMessage(ctx, path, m, data)
+		reply.TokenIndex = index
+		return sendErr
+	})
+	c.observeMessages(err, reply)
+
+	// Send
+	return reply, err
+}
+
+// Posts a message's JSON packet. Once a request
+// has been made, the reply is returned even on
+// failure, holding whatever error Postmark sent
+// back
+func (c *Client) postMessage(ctx context.Context, path string, m *PMMail, data []byte) (*Reply, error) {
+	reply := &Reply{Sandbox: c.Sandbox()}
+	if err := c.withMessageLog(m).postJSON(ctx, path, data, reply); err != nil {
+		reply.setError(err)
+		return reply, err
+	}
+
+	return reply, reply.Err()
+}
+
+// Joins an API path onto a configured base URL,
+// or the production API when base is empty
+func endpointURL(base, path string) string {
+	if base == "" {
+		base = __POSTMARK_URL__
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
+// Posts a JSON packet to a Postmark API path
+// and decodes the JSON response into v
+func (c *Client) postJSON(ctx context.Context, path string, data []byte, v interface{}) error {
+	return c.requestJSON(ctx, "POST", path, data, v)
+}
+
+// Gets a Postmark API path, which may include a
+// query string, and decodes the JSON response
+// into v
+func (c *Client) getJSON(ctx context.Context, path string, v interface{}) error {
+	return c.requestJSON(ctx, "GET", path, nil, v)
+}
+
+// Returns an API path without its query string,
+// which may hold search terms such as addresses
+// that shouldn't be logged
+func pathOnly(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// Makes a request to a Postmark API path with a
+// JSON packet, or no body when data is nil, and
+// decodes the JSON response into v
+func (c *Client) requestJSON(ctx context.Context, method, path string, data []byte, v interface{}) error {
+	err := c.hookedRequestJSON(ctx, method, path, data, v)
+	if err != nil {
+		c.log(ctx, slog.LevelError, "Postmark request failed", slog.String("method", method), slog.String("path", pathOnly(path)), slog.Any("error", err))
+	}
+	return err
+}
+
+// Makes a request as requestJSON does, within
+// any WithTimeout limit
+func (c *Client) timedRequestJSON(ctx context.Context, method, path string, data []byte, v interface{}) error {
+	if c.timeout <= 0 {
+		return c.retryRequestJSON(ctx, method, path, data, v)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	err := c.retryRequestJSON(timeoutCtx, method, path, data, v)
+	if err != nil && ctx.Err() == nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %w", ErrTimeout, c.timeout, err)
+	}
+	return err
+}
+
+// Makes a request as requestJSON does,
+// retrying as configured by WithRetries
+func (c *Client) retryRequestJSON(ctx context.Context, method, path string, data []byte, v interface{}) error {
+	for attempt := 1; ; attempt++ {
+		retry, err := c.tryRequestJSON(ctx, method, path, data, v)
+		if err == nil || !retry || attempt > c.retries {
+			if err != nil && attempt > 1 {
+				return fmt.Errorf("[Postmark] Giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		delay := retryDelay(c.retryDelay, attempt)
+		var rateLimitErr *RateLimitError
+		if errors.As(err, &rateLimitErr) && rateLimitErr.RetryAfter > 0 {
+			delay = rateLimitErr.RetryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return fmt.Errorf("[Postmark] Giving up after %d attempts, the next retry would pass the deadline: %w", attempt, err)
+		}
+
+		c.log(ctx, slog.LevelWarn, "Retrying Postmark request", slog.String("path", pathOnly(path)), slog.Int("attempt", attempt), slog.Duration("delay", delay), slog.Any("error", err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("[Postmark] Request not completed after %d attempts: %w", attempt, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Returns the exponential backoff before the
+// retry following the given attempt, jittered
+// to between half and all of the full delay
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	if attempt > 30 {
+		attempt = 30
+	}
+	delay := base << uint(attempt-1)
+	if half := int64(delay / 2); half > 0 {
+		delay = time.Duration(half + rand.Int63n(half+1))
+	}
+	return delay
+}
+
+// Makes a single attempt at a request,
+// reporting whether a failure is worth retrying
+func (c *Client) tryRequestJSON(ctx context.Context, method, path string, data []byte, v interface{}) (retry bool, err error) {
+	if c.limiter != nil {
+		waited := time.Now()
+		if err := c.limiter.Wait(ctx); err != nil {
+			return false, fmt.Errorf("[Postmark] Request not completed waiting for the rate limiter: %w", err)
+		}
+		c.logLimiterWait(ctx, time.Since(waited))
+	}
+
+	payload, encoding, err := c.encodeBody(data)
+	if err != nil {
+		return false, err
+	}
+
+	var badata io.Reader
+	if data != nil {
+		badata = bytes.NewBuffer(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, endpointURL(c.endpoint, path), badata)
+	if err != nil {
+		return false, err
+	}
+	if encoding != "" {
+		request.Header.Set("Content-Encoding", encoding)
+	}
+
+	request.Header.Set("Accept", "application/json")
+	if data != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	request.Header.Set(c.tokenHeader, c.apiKey)
+	request.Header.Set("User-Agent", c.userAgent)
+	for name, values := range c.requestHeaders {
+		request.Header[name] = append([]string(nil), values...)
+	}
+
+	start := time.Now()
+	status := 0
+	defer func() {
+		c.observe(time.Since(start), status, err, v)
+		if c.lastStatus != nil {
+			*c.lastStatus = status
+		}
+	}()
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		c.debug(request, data, start, nil, nil, err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return false, fmt.Errorf("[Postmark] Request not completed: %w", ctxErr)
+		}
+		return true, err
+	}
+	defer response.Body.Close()
+	status = response.StatusCode
+	defer recordResponse(v, response)
+
+	// Postmark's own errors come from the body, so
+	// it's read before the status is looked at
+	body, err := readBody(response)
+	c.debug(request, data, start, response, body, err)
+	if err != nil {
+		// Below 500 Postmark may already have
+		// acted on the request, so it isn't retried
+		return response.StatusCode >= 500, err
+	}
+
+	switch {
+	case status == 401:
+		if pmErr := decodePostmarkError(status, body); pmErr != nil {
+			return false, pmErr
+		}
+		return false, statusError(status, "Missing headers", body)
+	case status == 404:
+		if pmErr := decodePostmarkError(status, body); pmErr != nil {
+			return false, pmErr
+		}
+		return false, statusError(status, "Page not found", body)
+	case status == 422:
+		if pmErr := decodePostmarkError(status, body); pmErr != nil {
+			return false, pmErr
+		}
+		return false, statusError(status, "Bad JSON", body)
+	case status == 429:
+		return true, &RateLimitError{RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now())}
+	case status >= 500:
+		// Still retried, but with whatever error
+		// Postmark explained the failure with
+		if pmErr := decodePostmarkError(status, body); pmErr != nil {
+			return true, pmErr
+		}
+		switch status {
+		case 500:
+			return true, statusError(status, "Server error", body)
+		case 503:
+			return true, statusError(status, "Service unavailable", body)
+		}
+		return true, statusError(status, http.StatusText(status), body)
+	case status >= 400:
+		if pmErr := decodePostmarkError(status, body); pmErr != nil {
+			return false, pmErr
+		}
+		return false, statusError(status, http.StatusText(status), body)
+	case status < 200 || status >= 300:
+		return false, statusError(status, http.StatusText(status), body)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		// A proxy in front of Postmark may answer
+		// with an HTML page rather than bad JSON
+		if contentType := response.Header.Get("Content-Type"); !isJSON(contentType) {
+			return false, fmt.Errorf("[Postmark] HTTP %d : Unexpected %q response: %q", response.StatusCode, contentType, snippet(body))
+		}
+		return false, fmt.Errorf("[Postmark] HTTP %d : Invalid JSON response (%s): %q", response.StatusCode, err, snippet(body))
+	}
+
+	return false, nil
+}
+
+// Sets the status and headers of a response
+// on the replies decoded from it
+func recordResponse(v interface{}, response *http.Response) {
+	switch v := v.(type) {
+	case *Reply:
+		v.StatusCode = response.StatusCode
+		v.Header = response.Header
+	case *[]Reply:
+		for i := range *v {
+			(*v)[i].StatusCode = response.StatusCode
+			(*v)[i].Header = response.Header
+		}
+	}
+}
+
+// Reads a response body, refusing to buffer more
+// than __MAX_RESPONSE_SIZE__ bytes of it
+func readBody(response *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, int64(__MAX_RESPONSE_SIZE__)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > __MAX_RESPONSE_SIZE__ {
+		return nil, fmt.Errorf("[Postmark] HTTP %d : Response exceeds %d bytes", response.StatusCode, __MAX_RESPONSE_SIZE__)
+	}
+	return body, nil
+}
+
+// Reports whether a Content-Type is JSON. An
+// empty Content-Type is given the benefit of
+// the doubt and decoded anyway
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// Returns the error for an unsuccessful HTTP
+// status, with the start of any body it came with
+func statusError(status int, reason string, body []byte) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &httpStatusError{status, fmt.Sprintf("[Postmark] HTTP error %d : %s", status, reason)}
+	}
+	return &httpStatusError{status, fmt.Sprintf("[Postmark] HTTP error %d : %s: %q", status, reason, snippet(body))}
+}
+
+// An unsuccessful HTTP status Postmark didn't
+// explain with an error code
+type httpStatusError struct {
+	status  int
+	message string
+}
+
+func (e *httpStatusError) Error() string {
+	return e.message
+}
+
+// Returns the start of a response body,
+// for use in error messages
+func snippet(body []byte) string {
+	const max = 200
+	if len(body) > max {
+		return string(body[:max]) + "..."
+	}
+	return string(body)
+}
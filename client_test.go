@@ -0,0 +1,649 @@
+package postmark
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewClientOptions(t *testing.T) {
+	hc := &http.Client{}
+	c, err := NewClient("1234567",
+		WithHTTPClient(hc),
+		WithEndpoint("http://localhost:8080"),
+		WithUserAgent("test-agent"),
+		WithMessageStream("broadcast"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %s", err)
+	}
+	if c.httpClient != hc || c.endpoint != "http://localhost:8080" || c.userAgent != "test-agent" || c.messageStream != "broadcast" {
+		t.Errorf("Options not applied: %+v", c)
+	}
+
+	for name, opt := range map[string]Option{
+		"nil HTTP client":   WithHTTPClient(nil),
+		"relative endpoint": WithEndpoint("api.postmarkapp.com"),
+		"empty user agent":  WithUserAgent(""),
+	} {
+		if _, err := NewClient("1234567", opt); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestNewClientToken(t *testing.T) {
+	for _, token := range []string{"", " \n"} {
+		if _, err := NewClient(token); err == nil {
+			t.Errorf("Expected an error for server token %q", token)
+		}
+	}
+	if _, err := CreatePMMail("").Send(); err == nil || !strings.Contains(err.Error(), "server token") {
+		t.Errorf("Expected CreatePMMail to report the missing token from Send, got %v", err)
+	}
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	t.Setenv(ServerTokenEnv, " \t\n")
+	if _, err := NewClientFromEnv(); err == nil || !strings.Contains(err.Error(), ServerTokenEnv) {
+		t.Errorf("Expected an error naming %s when it is blank, got %v", ServerTokenEnv, err)
+	}
+
+	t.Setenv(ServerTokenEnv, "1234567\n")
+	c, err := NewClientFromEnv(WithUserAgent("test-agent"))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %s", err)
+	}
+	if c.apiKey != "1234567" || c.userAgent != "test-agent" {
+		t.Errorf("Unexpected client %+v", c)
+	}
+}
+
+func TestClientSend(t *testing.T) {
+	var token, agent string
+	var message map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Postmark-Server-Token")
+		agent = r.Header.Get("User-Agent")
+		json.NewDecoder(r.Body).Decode(&message)
+		w.Write([]byte(`{"ErrorCode": 0, "Message": "OK", "MessageID": "abc-123"}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient("1234567", WithEndpoint(server.URL), WithMessageStream("broadcast"))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %s", err)
+	}
+
+	m := testMail()
+	reply, err := c.Send(m)
+	if err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+	if reply.MessageID != "abc-123" || token != "1234567" || agent != defaultUserAgent() {
+		t.Errorf("Unexpected reply %+v with token %q and agent %q", reply, token, agent)
+	}
+	if message["MessageStream"] != "broadcast" {
+		t.Errorf("Expected the client's message stream, got %v", message["MessageStream"])
+	}
+	if m.MessageStream != "" {
+		t.Errorf("Expected the client default to leave the message untouched, got %q", m.MessageStream)
+	}
+
+	literal := &Message{
+		Sender:   "dave@flyclops.com",
+		To:       "someone@example.com",
+		Subject:  "Hello",
+		TextBody: "Hello",
+	}
+	if _, err := c.Send(literal); err != nil || token != "1234567" {
+		t.Fatalf("Expected a Message literal to be sent with the client's token, got %q and %v", token, err)
+	}
+	token = ""
+	if _, err := literal.Send(); err == nil || !strings.Contains(err.Error(), "Client.Send") || token != "" {
+		t.Errorf("Expected a Message literal's own Send to fail without a request, got %q and %v", token, err)
+	}
+	if _, err := literal.SendWithTemplate(); err == nil {
+		t.Errorf("Expected a Message literal's own SendWithTemplate to fail")
+	}
+
+	m = c.NewMail()
+	m.Sender = "dave@flyclops.com"
+	m.To = "someone@example.com"
+	m.Subject = "Hello"
+	m.TextBody = "Hello"
+	m.MessageStream = "outbound"
+	if _, err := m.Send(); err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+	if message["MessageStream"] != "outbound" {
+		t.Errorf("Expected the message's own stream, got %v", message["MessageStream"])
+	}
+}
+
+func TestClientWithToken(t *testing.T) {
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Postmark-Server-Token")
+		w.Write([]byte(`{"ErrorCode": 0}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	tenant, err := c.WithToken("7654321")
+	if err != nil {
+		t.Fatalf("Unexpected error deriving client: %s", err)
+	}
+	if tenant.httpClient != c.httpClient {
+		t.Errorf("Expected the derived client to share the HTTP client")
+	}
+
+	if _, err := tenant.Send(testMail()); err != nil || token != "7654321" {
+		t.Errorf("Expected a send with the derived token, got %q and %v", token, err)
+	}
+	if _, err := c.Send(testMail()); err != nil || token != "1234567" {
+		t.Errorf("Expected the original token to be left alone, got %q and %v", token, err)
+	}
+
+	if _, err := c.WithToken(""); err == nil {
+		t.Errorf("Expected an error for a blank token")
+	}
+}
+
+func TestSandboxClient(t *testing.T) {
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Postmark-Server-Token")
+		if r.URL.Path == "/email/batch" {
+			w.Write([]byte(`[{"ErrorCode": 0}]`))
+			return
+		}
+		w.WriteHeader(422)
+		w.Write([]byte(`{"ErrorCode": 300, "Message": "Invalid 'From' address"}`))
+	}))
+	defer server.Close()
+
+	c, err := NewSandboxClient(WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %s", err)
+	}
+	if !c.Sandbox() {
+		t.Errorf("Expected a sandbox client")
+	}
+
+	reply, err := c.Send(testMail())
+	if !IsErrorCode(err, ErrCodeInvalidEmailRequest) {
+		t.Errorf("Expected validation errors to surface in sandbox mode, got %v", err)
+	}
+	if token != SandboxToken || reply == nil || !reply.Sandbox {
+		t.Errorf("Expected a sandbox reply sent with %q, got %+v with %q", SandboxToken, reply, token)
+	}
+
+	replies, err := c.SendBatch([]*PMMail{testMail()})
+	if err != nil || !replies[0].Sandbox {
+		t.Errorf("Expected sandbox batch replies, got %+v and %v", replies, err)
+	}
+
+	if c, _ := NewClient("1234567"); c.Sandbox() {
+		t.Errorf("Expected a client with a real token not to be a sandbox")
+	}
+}
+
+func TestClientSendBatch(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`[{"ErrorCode": 0}, {"ErrorCode": 406, "Message": "Inactive recipient"}]`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	replies, err := c.SendBatch([]*PMMail{testMail(), testMail()})
+	if err != nil {
+		t.Fatalf("Unexpected error sending batch: %s", err)
+	}
+	if path != "/email/batch" || len(replies) != 2 || replies[1].ErrorCode != 406 {
+		t.Errorf("Unexpected replies %+v from %q", replies, path)
+	}
+	if replies[0].Err() != nil || !IsInactiveRecipient(replies[1].Err()) {
+		t.Errorf("Expected only the second reply to be an inactive recipient error, got %v and %v", replies[0].Err(), replies[1].Err())
+	}
+}
+
+func TestClientReplyResponse(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		w.WriteHeader(status)
+		if r.URL.Path == "/email/batch" {
+			w.Write([]byte(`[{"ErrorCode": 0}, {"ErrorCode": 0}]`))
+			return
+		}
+		w.Write([]byte(`{"ErrorCode": 300, "Message": "Invalid email request"}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	replies, err := c.SendBatch([]*PMMail{testMail(), testMail()})
+	if err != nil {
+		t.Fatalf("Unexpected error sending batch: %s", err)
+	}
+	for i, reply := range replies {
+		if reply.StatusCode != http.StatusOK || reply.Header.Get("X-Request-Id") != "req-1" {
+			t.Errorf("Expected reply %d to carry the batch response, got %d and %v", i, reply.StatusCode, reply.Header)
+		}
+	}
+
+	status = http.StatusUnprocessableEntity
+	reply, err := c.Send(testMail())
+	if err == nil {
+		t.Fatalf("Expected an error for a rejected message")
+	}
+	if reply == nil || reply.StatusCode != status || reply.Header.Get("X-Request-Id") != "req-1" {
+		t.Errorf("Expected the rejected reply to carry the response, got %+v", reply)
+	}
+}
+
+func TestClientCustomHeadersOnlyInJSON(t *testing.T) {
+	var header http.Header
+	var message RecordedMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		json.NewDecoder(r.Body).Decode(&message)
+		w.Write([]byte(`{"ErrorCode": 0, "Message": "OK"}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	m := testMail()
+	m.AddCustomHeader("X-Postmark-Server-Token", "stolen")
+	m.AddCustomHeader("Content-Type", "text/plain")
+	m.AddCustomHeader("X-H1", "Dave Rulez")
+	if _, err := c.Send(m); err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+
+	if header.Get("X-Postmark-Server-Token") != "1234567" || header.Get("Content-Type") != "application/json" || header.Get("X-H1") != "" {
+		t.Errorf("Expected custom headers to stay out of the HTTP request, got %v", header)
+	}
+	if len(message.Headers) != 3 || message.Headers[2] != (RecordedHeader{"X-H1", "Dave Rulez"}) {
+		t.Errorf("Expected the custom headers in the JSON, got %v", message.Headers)
+	}
+}
+
+func TestWithRequestHeader(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.Write([]byte(`{"ErrorCode": 0, "Message": "OK"}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient("1234567", WithEndpoint(server.URL), WithRequestHeader("X-Proxy-Auth", "secret"), WithRequestHeader("x-trace", "a"), WithRequestHeader("X-Trace", "b"))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %s", err)
+	}
+	m := testMail()
+	m.AddCustomHeader("X-Campaign", "spring")
+	if _, err := c.Send(m); err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+	if header.Get("X-Proxy-Auth") != "secret" || len(header.Values("X-Trace")) != 2 || header.Get("X-Campaign") != "" {
+		t.Errorf("Expected only the request headers on the HTTP request, got %v", header)
+	}
+
+	// A transport that changes a request's headers
+	// mustn't change the client's
+	hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.Header["X-Trace"][0] = "changed"
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	c, _ = NewClient("1234567", WithEndpoint(server.URL), WithHTTPClient(hc), WithRequestHeader("X-Trace", "a"))
+	for i := 0; i < 2; i++ {
+		if _, err := c.Send(testMail()); err != nil {
+			t.Fatalf("Unexpected error sending: %s", err)
+		}
+	}
+	if v := c.requestHeaders.Get("X-Trace"); v != "a" {
+		t.Errorf("Expected the client's header to be left alone, got %q", v)
+	}
+
+	for _, name := range []string{"content-type", "X-Postmark-Server-Token", "User-Agent"} {
+		if _, err := NewClient("1234567", WithRequestHeader(name, "x")); err == nil {
+			t.Errorf("Expected an error replacing the %s header", name)
+		}
+	}
+}
+
+func TestClientConcurrentSends(t *testing.T) {
+	recorder := NewRecorder()
+	c, _ := NewClient("1234567", WithHTTPClient(recorder.Client()), WithMessageStream(MessageStreamBroadcast))
+
+	shared := c.NewMail()
+	shared.Sender = "dave@flyclops.com"
+	shared.To = "someone@example.com"
+	shared.Subject = "Hello"
+	shared.TextBody = "Hello"
+	shared.AddCustomHeader("X-H1", "Dave Rulez")
+	shared.AddMetadata("user", "42")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, err := shared.Send(); err != nil {
+				t.Errorf("Unexpected error sending a shared message: %s", err)
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			m := shared.Clone()
+			m.AddRecipient(fmt.Sprintf("r%d@example.com", i))
+			if _, err := c.Send(m); err != nil {
+				t.Errorf("Unexpected error sending a clone: %s", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := c.SendBatch([]*PMMail{shared, shared}); err != nil {
+				t.Errorf("Unexpected error sending a batch: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sent := recorder.Sent(); len(sent) != 80 {
+		t.Errorf("Expected 80 messages sent, got %d", len(sent))
+	}
+}
+
+func TestClientBadURL(t *testing.T) {
+	c := newClient("1234567")
+	c.endpoint = "://bad url"
+	if err := c.postJSON(context.Background(), "/email", []byte("{}"), new(Reply)); err == nil {
+		t.Errorf("Expected an error for a malformed URL")
+	}
+}
+
+func TestClientMalformedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ErrorCode": 0, "Message": "OK"`))
+	}))
+	defer server.Close()
+
+	c := newClient("1234567")
+	c.endpoint = server.URL
+	err := c.postJSON(context.Background(), "/email", []byte("{}"), new(Reply))
+	if err == nil {
+		t.Fatalf("Expected an error for a truncated response")
+	}
+	if !strings.Contains(err.Error(), `\"Message\": \"OK\"`) {
+		t.Errorf("Expected the response body in the error, got %s", err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var requests int32
+	statuses := []int{500, 503, 200}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		w.WriteHeader(statuses[n-1])
+		w.Write([]byte(`{"ErrorCode": 0, "MessageID": "abc-123"}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithRetries(2, time.Millisecond))
+	reply, err := c.Send(testMail())
+	if err != nil {
+		t.Fatalf("Unexpected error after retries: %s", err)
+	}
+	if reply.MessageID != "abc-123" || atomic.LoadInt32(&requests) != 3 {
+		t.Errorf("Expected success on the 3rd attempt, got %+v after %d", reply, atomic.LoadInt32(&requests))
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	c, _ = NewClient("1234567", WithEndpoint(server.URL), WithRetries(1, time.Millisecond))
+	_, err = c.Send(testMail())
+	if err == nil || !strings.Contains(err.Error(), "2 attempts") {
+		t.Errorf("Expected an error naming 2 attempts, got %v", err)
+	}
+}
+
+func TestClientRetriesNotRetryable(t *testing.T) {
+	for _, status := range []int{401, 422} {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(status)
+		}))
+
+		c, _ := NewClient("1234567", WithEndpoint(server.URL), WithRetries(3, time.Millisecond))
+		if _, err := c.Send(testMail()); err == nil {
+			t.Errorf("Expected an error for HTTP %d", status)
+		}
+		if atomic.LoadInt32(&requests) != 1 {
+			t.Errorf("Expected HTTP %d not to be retried, got %d requests", status, atomic.LoadInt32(&requests))
+		}
+		server.Close()
+	}
+}
+
+func TestClientRetriesUnreadableBody(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte(`{"ErrorCode": 0`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithRetries(2, time.Millisecond))
+	if _, err := c.Send(testMail()); err == nil {
+		t.Errorf("Expected an error for a short response body")
+	}
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("Expected a 200 with a short body not to be retried, got %d requests", atomic.LoadInt32(&requests))
+	}
+}
+
+func TestClientRetriesDeadline(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithRetries(5, time.Second))
+	if _, err := c.SendContext(ctx, testMail()); err == nil {
+		t.Errorf("Expected an error when retries would pass the deadline")
+	}
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("Expected no retry past the deadline, got %d requests", atomic.LoadInt32(&requests))
+	}
+	if _, err := NewClient("1234567", WithRetries(-1, time.Second)); err == nil {
+		t.Errorf("Expected an error for negative retries")
+	}
+}
+
+func TestClientUnexpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(413)
+		w.Write([]byte("Request Entity Too Large"))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	_, err := c.Send(testMail())
+	if err == nil {
+		t.Fatalf("Expected an error for HTTP 413")
+	}
+	if !strings.Contains(err.Error(), "413") || !strings.Contains(err.Error(), "Request Entity Too Large") {
+		t.Errorf("Expected the status and body in the error, got %s", err)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	c, err := NewClient("1234567", WithEndpoint(server.URL), WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %s", err)
+	}
+	_, err = c.Send(testMail())
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected ErrTimeout and context.DeadlineExceeded, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c.timeout = time.Minute
+	_, err = c.SendContext(ctx, testMail())
+	if errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context's deadline to win, got %v", err)
+	}
+
+	if _, err := NewClient("1234567", WithTimeout(-time.Second)); err == nil {
+		t.Errorf("Expected an error for a negative timeout")
+	}
+}
+
+func TestClientStatuses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		check  func(err error) bool
+	}{
+		{"200", 200, `{"ErrorCode": 0, "Message": "OK", "MessageID": "abc-123"}`, func(err error) bool {
+			return err == nil
+		}},
+		{"401", 401, `{"ErrorCode": 10, "Message": "Bad or missing API token"}`, func(err error) bool {
+			return IsErrorCode(err, ErrCodeBadAPIToken)
+		}},
+		{"403", 403, "Forbidden", func(err error) bool {
+			return err != nil && strings.Contains(err.Error(), "403") && strings.Contains(err.Error(), "Forbidden")
+		}},
+		{"422", 422, `{"ErrorCode": 300, "Message": "Invalid email request"}`, func(err error) bool {
+			return IsErrorCode(err, ErrCodeInvalidEmailRequest)
+		}},
+		{"429", 429, "", func(err error) bool {
+			_, limited := IsRateLimited(err)
+			return limited
+		}},
+		{"500", 500, "Oops", func(err error) bool {
+			return err != nil && strings.Contains(err.Error(), "500") && strings.Contains(err.Error(), "Oops")
+		}},
+		{"503", 503, "", func(err error) bool {
+			return err != nil && strings.Contains(err.Error(), "Service unavailable")
+		}},
+		{"304", 304, "", func(err error) bool {
+			return err != nil && strings.Contains(err.Error(), "304")
+		}},
+		{"malformed JSON", 200, `{"ErrorCode": 0,`, func(err error) bool {
+			return err != nil && strings.Contains(err.Error(), "Invalid JSON")
+		}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			c, _ := NewClient("1234567", WithEndpoint(server.URL))
+			if _, err := c.Send(testMail()); !test.check(err) {
+				t.Errorf("Unexpected result for HTTP %d: %v", test.status, err)
+			}
+		})
+	}
+}
+
+func TestClientErrorReply(t *testing.T) {
+	status := 500
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ErrorCode": 100, "Message": "Maintenance"}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	for _, status = range []int{404, 422, 500, 503} {
+		reply, err := c.Send(testMail())
+		if !IsErrorCode(err, 100) {
+			t.Errorf("Expected Postmark's error code for HTTP %d, got %v", status, err)
+		}
+		if reply == nil || reply.ErrorCode != 100 || reply.Message != "Maintenance" || reply.StatusCode != status {
+			t.Errorf("Expected the decoded reply for HTTP %d, got %+v", status, reply)
+		}
+	}
+}
+
+func TestClientNonJSONResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><body>Bad Gateway</body></html>"))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	_, err := c.Send(testMail())
+	if err == nil {
+		t.Fatalf("Expected an error for an HTML response")
+	}
+	if !strings.Contains(err.Error(), "200") || !strings.Contains(err.Error(), "text/html") || !strings.Contains(err.Error(), "Bad Gateway") {
+		t.Errorf("Expected the status and body in the error, got %s", err)
+	}
+}
+
+func TestClientResponseSizeLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Message": "`))
+		w.Write([]byte(strings.Repeat("x", __MAX_RESPONSE_SIZE__)))
+		w.Write([]byte(`"}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	if _, err := c.Send(testMail()); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("Expected an error for an oversized response, got %v", err)
+	}
+}
+
+func TestIsJSON(t *testing.T) {
+	for contentType, expected := range map[string]bool{
+		"":                                true,
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"application/problem+json":        true,
+		"text/html":                       false,
+		"text/plain; charset=utf-8":       false,
+	} {
+		if isJSON(contentType) != expected {
+			t.Errorf("isJSON(%q): expected %v", contentType, expected)
+		}
+	}
+}
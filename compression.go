@@ -0,0 +1,66 @@
+package postmark
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// The size above which request bodies are gzip
+// compressed unless WithCompressionThreshold
+// says otherwise, 64KB
+const DefaultCompressionThreshold int = 64 * 1024
+
+// Gzip compresses request bodies over threshold
+// bytes, such as batches with attachments,
+// sending them with Content-Encoding: gzip. A
+// negative threshold turns compression off.
+// Size limits are checked against the message
+// before compression, as Postmark checks them
+func WithCompressionThreshold(threshold int) Option {
+	return func(c *Client) error {
+		c.compressAbove = threshold
+		return nil
+	}
+}
+
+// Returns the body to send for a JSON packet,
+// compressed if it is over the client's
+// threshold, and its Content-Encoding
+func (c *Client) encodeBody(data []byte) ([]byte, string, error) {
+	if c.compressAbove < 0 || len(data) <= c.compressAbove {
+		return data, "", nil
+	}
+
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := w.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return compressed.Bytes(), "gzip", nil
+}
+
+// Reads a request body, decompressing it if it
+// was sent with Content-Encoding: gzip
+func readRequestBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil {
+		return nil, nil
+	}
+	defer request.Body.Close()
+
+	var r io.Reader = request.Body
+	if request.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,51 @@
+package postmark
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	var encoding string
+	var message map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		body, err := readRequestBody(r)
+		if err != nil {
+			t.Errorf("Unexpected error reading request: %s", err)
+		}
+		message = nil
+		json.Unmarshal(body, &message)
+		w.Write([]byte(`{"ErrorCode": 0}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	if _, err := c.Send(testMail()); err != nil || encoding != "" {
+		t.Errorf("Expected a small message to be sent uncompressed, got %q and %v", encoding, err)
+	}
+
+	p := testMail()
+	p.TextBody = strings.Repeat("This is a test. ", DefaultCompressionThreshold/8)
+	if _, err := c.Send(p); err != nil || encoding != "gzip" || message["TextBody"] != p.TextBody {
+		t.Errorf("Expected a large message to be gzipped intact, got %q and %v", encoding, err)
+	}
+
+	c, _ = NewClient("1234567", WithEndpoint(server.URL), WithCompressionThreshold(-1))
+	if _, err := c.Send(p); err != nil || encoding != "" {
+		t.Errorf("Expected compression to be turned off, got %q and %v", encoding, err)
+	}
+}
+
+func TestEncodeBody(t *testing.T) {
+	c, _ := NewClient("1234567", WithCompressionThreshold(10))
+	data := bytes.Repeat([]byte("a"), 100)
+	body, encoding, err := c.encodeBody(data)
+	if err != nil || encoding != "gzip" || len(body) >= len(data) {
+		t.Errorf("Expected a smaller gzipped body, got %d bytes %q and %v", len(body), encoding, err)
+	}
+}
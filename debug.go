@@ -0,0 +1,140 @@
+package postmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// The value sent in place of a redacted header
+// in a DebugEvent
+const Redacted string = "REDACTED"
+
+// Headers that carry credentials, and so never
+// appear in a DebugEvent
+var redactedHeaders = []string{__SERVER_TOKEN_HEADER__, __ACCOUNT_TOKEN_HEADER__, "Authorization", "Proxy-Authorization", "Cookie"}
+
+// One API call made by a Client, as passed to
+// the function given to WithDebugFunc. Retried
+// requests produce an event per attempt
+type DebugEvent struct {
+	Method        string
+	URL           string
+	RequestHeader http.Header
+
+	// The JSON sent, with the content of any
+	// attachments replaced by its size
+	RequestBody []byte
+
+	// The response, if one was received. These
+	// are zero when Err is a network error
+	StatusCode     int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+
+	Duration time.Duration
+	Err      error
+}
+
+// Calls fn with every request the client makes
+// and the response to it, for diagnosing what
+// Postmark was sent. The API token, headers
+// set by WithRequestHeader and other
+// credentials are redacted and attachments are
+// summarized, so events are safe to log. fn is called from
+// the sending goroutine, and must not keep the
+// event's header or body slices
+func WithDebugFunc(fn func(event DebugEvent)) Option {
+	return func(c *Client) error {
+		c.debugFunc = fn
+		return nil
+	}
+}
+
+// Reports an API call to the client's debug
+// function, if it has one
+func (c *Client) debug(request *http.Request, data []byte, start time.Time, response *http.Response, body []byte, err error) {
+	if c.debugFunc == nil {
+		return
+	}
+
+	event := DebugEvent{
+		Method:        request.Method,
+		URL:           request.URL.String(),
+		RequestHeader: redactHeader(request.Header, c.requestHeaders),
+		RequestBody:   summarizeAttachments(data, c.escapeHTML),
+		ResponseBody:  body,
+		Duration:      time.Since(start),
+		Err:           err,
+	}
+	if response != nil {
+		event.StatusCode = response.StatusCode
+		event.ResponseHeader = response.Header
+	}
+
+	c.debugFunc(event)
+}
+
+// Returns a copy of h with any credentials,
+// and the values of the headers in set, which
+// may hold a proxy's, replaced by Redacted
+func redactHeader(h http.Header, set http.Header) http.Header {
+	redacted := h.Clone()
+	for _, name := range redactedHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, Redacted)
+		}
+	}
+	for name := range set {
+		redacted.Set(name, Redacted)
+	}
+	return redacted
+}
+
+// Returns a JSON packet with the base64 content
+// of every attachment, in a single message or a
+// batch, replaced by a note of its size, and
+// HTML escaped as the client escapes it
+func summarizeAttachments(data []byte, escapeHTML bool) []byte {
+	var packet interface{}
+	if err := json.Unmarshal(data, &packet); err != nil {
+		return data
+	}
+	if !summarizeValue(packet) {
+		return data
+	}
+
+	summarized, err := marshalJSON(packet, escapeHTML)
+	if err != nil {
+		return data
+	}
+	return summarized
+}
+
+// Summarizes attachments anywhere within v,
+// reporting whether there were any
+func summarizeValue(v interface{}) bool {
+	found := false
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			found = summarizeValue(item) || found
+		}
+	case map[string]interface{}:
+		if attachments, ok := v["Attachments"].([]interface{}); ok {
+			for _, a := range attachments {
+				if a, ok := a.(map[string]interface{}); ok {
+					if content, ok := a["Content"].(string); ok {
+						a["Content"] = fmt.Sprintf("[%d bytes of base64]", len(content))
+						found = true
+					}
+				}
+			}
+		}
+		for _, item := range v {
+			found = summarizeValue(item) || found
+		}
+	}
+	return found
+}
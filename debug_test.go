@@ -0,0 +1,80 @@
+package postmark
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugFunc(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ErrorCode": 0, "Message": "OK"}`))
+	}))
+	defer server.Close()
+
+	var events []DebugEvent
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithRequestHeader("Proxy-Authorization", "Basic c2VjcmV0"), WithRequestHeader("X-Proxy-Key", "secret"), WithDebugFunc(func(event DebugEvent) {
+		events = append(events, event)
+	}))
+
+	data := []byte(strings.Repeat("attachment content ", 100))
+	p := testMail()
+	if err := p.AddAttachmentFromBytes("notes.txt", data, "text/plain"); err != nil {
+		t.Fatalf("Unexpected error attaching: %s", err)
+	}
+	if _, err := c.Send(p); err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 debug event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Method != "POST" || event.URL != server.URL+"/email" {
+		t.Errorf("Expected POST %s/email, got %s %s", server.URL, event.Method, event.URL)
+	}
+	if token := event.RequestHeader.Get("X-Postmark-Server-Token"); token != Redacted {
+		t.Errorf("Expected the server token to be redacted, got %q", token)
+	}
+	for _, name := range []string{"Proxy-Authorization", "X-Proxy-Key"} {
+		if value := event.RequestHeader.Get(name); value != Redacted {
+			t.Errorf("Expected the %s header to be redacted, got %q", name, value)
+		}
+	}
+	if strings.Contains(string(event.RequestBody), base64.StdEncoding.EncodeToString(data)) {
+		t.Errorf("Expected attachment content to be summarized: %s", event.RequestBody)
+	}
+	if !strings.Contains(string(event.RequestBody), "notes.txt") || !strings.Contains(string(event.RequestBody), "bytes of base64") {
+		t.Errorf("Expected the attachment's name and size: %s", event.RequestBody)
+	}
+	if event.StatusCode != 200 || !strings.Contains(string(event.ResponseBody), `"Message": "OK"`) {
+		t.Errorf("Expected the response in the event, got %d %s", event.StatusCode, event.ResponseBody)
+	}
+}
+
+func TestSummarizeAttachments(t *testing.T) {
+	batch := []byte(`[{"Attachments":[{"Name":"a.txt","Content":"aGVsbG8="}]},{"Subject":"Hi"}]`)
+	if summarized := string(summarizeAttachments(batch, false)); strings.Contains(summarized, "aGVsbG8=") || !strings.Contains(summarized, "[8 bytes of base64]") {
+		t.Errorf("Expected batch attachments to be summarized: %s", summarized)
+	}
+
+	plain := []byte(`{"Subject":"Hi"}`)
+	if summarized := summarizeAttachments(plain, false); string(summarized) != string(plain) {
+		t.Errorf("Expected a packet without attachments to be left alone: %s", summarized)
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{"Authorization": {"Bearer secret"}, "Cookie": {"session=secret"}, "Accept": {"application/json"}}
+	redacted := redactHeader(h, nil)
+	for _, name := range []string{"Authorization", "Cookie"} {
+		if value := redacted.Get(name); value != Redacted {
+			t.Errorf("Expected the %s header to be redacted, got %q", name, value)
+		}
+	}
+	if redacted.Get("Accept") != "application/json" || h.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Expected other headers and the original to be left alone, got %v and %v", redacted, h)
+	}
+}
@@ -0,0 +1,148 @@
+package postmark
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// An error code from Postmark's API, returned in
+// a Reply's ErrorCode when a message is rejected
+type ErrorCode int
+
+// The error codes documented by Postmark
+const (
+	ErrCodeBadAPIToken                 ErrorCode = 10
+	ErrCodeInvalidEmailRequest         ErrorCode = 300
+	ErrCodeSenderSignatureNotFound     ErrorCode = 400
+	ErrCodeSenderSignatureNotConfirmed ErrorCode = 401
+	ErrCodeInvalidJSON                 ErrorCode = 402
+	ErrCodeIncompatibleJSON            ErrorCode = 403
+	ErrCodeNotAllowedToSend            ErrorCode = 405
+	ErrCodeInactiveRecipient           ErrorCode = 406
+	ErrCodeJSONRequired                ErrorCode = 409
+	ErrCodeTooManyBatchMessages        ErrorCode = 410
+	ErrCodeForbiddenAttachmentType     ErrorCode = 411
+	ErrCodeAccountPending              ErrorCode = 412
+	ErrCodeAccountMayNotSend           ErrorCode = 413
+)
+
+// Returned, wrapping context.DeadlineExceeded,
+// when a send runs out of a client's WithTimeout
+var ErrTimeout = errors.New("[Postmark] Request timed out")
+
+// Returned by Send when Postmark rejects a message
+// with a non-zero ErrorCode. Use errors.As to get
+// at the code and Postmark's explanation of it.
+// StatusCode is the HTTP status of the response,
+// which is 200 when a message in an otherwise
+// successful request was rejected
+type PostmarkError struct {
+	StatusCode int
+	ErrorCode  ErrorCode
+	Message    string
+}
+
+func (e *PostmarkError) Error() string {
+	status := ""
+	if e.StatusCode != 0 && e.StatusCode != http.StatusOK {
+		status = fmt.Sprintf("HTTP error %d, ", e.StatusCode)
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("[Postmark] %sError Code: %d", status, e.ErrorCode)
+	}
+	return fmt.Sprintf("[Postmark] %sError Code: %d : %s", status, e.ErrorCode, e.Message)
+}
+
+// Reports whether Postmark refused to send to an
+// inactive recipient
+func (e *PostmarkError) IsInactiveRecipient() bool {
+	return e.ErrorCode == ErrCodeInactiveRecipient
+}
+
+// Reports whether Postmark rejected the message
+// as invalid, such as for a malformed address
+func (e *PostmarkError) IsInvalidEmailRequest() bool {
+	return e.ErrorCode == ErrCodeInvalidEmailRequest
+}
+
+// Reports whether the server token was missing
+// or not recognised
+func (e *PostmarkError) IsBadAPIToken() bool {
+	return e.ErrorCode == ErrCodeBadAPIToken
+}
+
+// Reports whether err is, or wraps, a
+// PostmarkError with the given code
+func IsErrorCode(err error, code ErrorCode) bool {
+	var pmErr *PostmarkError
+	return errors.As(err, &pmErr) && pmErr.ErrorCode == code
+}
+
+// Reports whether err is Postmark refusing to send
+// to a recipient marked inactive after bouncing
+// or complaining about spam
+func IsInactiveRecipient(err error) bool {
+	return IsErrorCode(err, ErrCodeInactiveRecipient)
+}
+
+// Decodes the ErrorCode and Message Postmark sends
+// with a failed request, or returns nil if the
+// body doesn't hold one
+func decodePostmarkError(statusCode int, body []byte) *PostmarkError {
+	var reply Reply
+	if err := json.Unmarshal(body, &reply); err != nil || reply.ErrorCode == 0 {
+		return nil
+	}
+
+	return &PostmarkError{StatusCode: statusCode, ErrorCode: ErrorCode(reply.ErrorCode), Message: reply.Message}
+}
+
+// Returned when Postmark responds with HTTP 429
+// because too many requests were sent. RetryAfter
+// is how long Postmark asked callers to wait, or
+// zero if it didn't say
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter == 0 {
+		return "[Postmark] HTTP error 429 : Too many requests"
+	}
+	return fmt.Sprintf("[Postmark] HTTP error 429 : Too many requests, retry after %s", e.RetryAfter)
+}
+
+// Reports whether err is, or wraps, a
+// RateLimitError, returning how long Postmark
+// asked callers to wait before trying again
+func IsRateLimited(err error) (time.Duration, bool) {
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		return 0, false
+	}
+	return rateLimitErr.RetryAfter, true
+}
+
+// Parses a Retry-After header, which holds either
+// a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
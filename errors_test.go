@@ -0,0 +1,154 @@
+package postmark
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPostmarkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ErrorCode": 406, "Message": "You tried to send to a recipient that has been marked as inactive."}`))
+	}))
+	defer server.Close()
+
+	p := testMail()
+	p.Endpoint = server.URL
+	reply, err := p.Send()
+
+	var pmErr *PostmarkError
+	if !errors.As(err, &pmErr) {
+		t.Fatalf("Expected a *PostmarkError, got %v", err)
+	}
+	if pmErr.ErrorCode != ErrCodeInactiveRecipient || pmErr.Message != reply.Message {
+		t.Errorf("Unexpected error %+v", pmErr)
+	}
+	if !IsInactiveRecipient(err) || IsErrorCode(err, ErrCodeInvalidEmailRequest) {
+		t.Errorf("Expected only an inactive recipient error, got %v", err)
+	}
+	if IsInactiveRecipient(errors.New("Error Code: 406")) || IsInactiveRecipient(nil) {
+		t.Errorf("Expected untyped errors not to match")
+	}
+	if reply == nil || reply.ErrorCode != 406 {
+		t.Errorf("Expected the reply alongside the error, got %+v", reply)
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(429)
+			w.Write([]byte("<html>Slow down</html>"))
+			return
+		}
+		w.Write([]byte(`{"ErrorCode": 0, "MessageID": "abc-123"}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	_, err := c.Send(testMail())
+
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("Expected a *RateLimitError, got %v", err)
+	}
+	if rateLimitErr.RetryAfter != time.Second {
+		t.Errorf("Expected to be asked to wait 1s, got %s", rateLimitErr.RetryAfter)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	c, _ = NewClient("1234567", WithEndpoint(server.URL), WithRetries(1, time.Millisecond))
+	start := time.Now()
+	if _, err := c.Send(testMail()); err != nil {
+		t.Fatalf("Unexpected error after retrying: %s", err)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("Expected the retry to wait for Retry-After, waited %s", waited)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"-5":                            0,
+		"soon":                          0,
+		"Tue, 02 Jan 2024 15:04:35 GMT": 30 * time.Second,
+		"Tue, 02 Jan 2024 15:00:00 GMT": 0,
+	}
+
+	for value, expected := range tests {
+		if wait := parseRetryAfter(value, now); wait != expected {
+			t.Errorf("parseRetryAfter(%q): expected %s, got %s", value, expected, wait)
+		}
+	}
+}
+
+func TestPostmarkErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		w.Write([]byte(`{"ErrorCode": 300, "Message": "Invalid 'To' address: 'dave@@flyclops.com'."}`))
+	}))
+	defer server.Close()
+
+	p := testMail()
+	p.Endpoint = server.URL
+	reply, err := p.Send()
+
+	var pmErr *PostmarkError
+	if !errors.As(err, &pmErr) {
+		t.Fatalf("Expected a *PostmarkError, got %v", err)
+	}
+	if reply == nil || reply.ErrorCode != 300 || reply.Message != pmErr.Message {
+		t.Errorf("Expected the reply to carry Postmark's error, got %+v", reply)
+	}
+	if pmErr.StatusCode != 422 || !pmErr.IsInvalidEmailRequest() || pmErr.IsInactiveRecipient() {
+		t.Errorf("Unexpected error %+v", pmErr)
+	}
+	if expected := "[Postmark] HTTP error 422, Error Code: 300 : Invalid 'To' address: 'dave@@flyclops.com'."; err.Error() != expected {
+		t.Errorf("Expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestIsRateLimited(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "Tue, 02 Jan 2024 15:04:05 GMT")
+		w.WriteHeader(429)
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithRetries(2, time.Millisecond))
+	_, err := c.Send(testMail())
+
+	if _, ok := IsRateLimited(err); !ok {
+		t.Errorf("Expected a rate limit error after exhausting retries, got %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+	if _, ok := IsRateLimited(errors.New("HTTP error 429")); ok {
+		t.Errorf("Expected untyped errors not to match")
+	}
+}
+
+func TestReplyOnFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	p := testMail()
+	p.Endpoint = server.URL
+	if reply, err := p.Send(); err == nil || reply == nil {
+		t.Errorf("Expected an error and a reply, got %v and %v", err, reply)
+	}
+}
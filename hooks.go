@@ -0,0 +1,66 @@
+package postmark
+
+import (
+	"context"
+	"time"
+)
+
+// The outcome of one call to the Postmark API,
+// such as a Send or one chunk of a batch, as
+// passed to the function given to WithOnSend
+type SendEvent struct {
+	// The API path called, such as "/email",
+	// without any query string
+	Path string
+
+	// The HTTP status of the last response, or
+	// zero if none was received
+	StatusCode int
+
+	// Postmark's error code for the request, or
+	// for a single message that was rejected.
+	// Zero when it was accepted
+	ErrorCode ErrorCode
+
+	// How long the call took, including any
+	// retries and rate limiter waits
+	Duration time.Duration
+
+	Err error
+}
+
+// Calls fn once each send has finished,
+// after any retries, for recording latency and
+// error rates without a logging dependency.
+// fn is called from the sending goroutine.
+// Without it nothing is called
+func WithOnSend(fn func(event SendEvent)) Option {
+	return func(c *Client) error {
+		c.onSend = fn
+		return nil
+	}
+}
+
+// Makes a request as timedRequestJSON does,
+// reporting the outcome to the client's OnSend
+// function
+func (c *Client) hookedRequestJSON(ctx context.Context, method, path string, data []byte, v interface{}) error {
+	if c.onSend == nil {
+		return c.timedRequestJSON(ctx, method, path, data, v)
+	}
+
+	var status int
+	hooked := *c
+	hooked.lastStatus = &status
+
+	start := time.Now()
+	err := hooked.timedRequestJSON(ctx, method, path, data, v)
+	c.onSend(SendEvent{
+		Path:       pathOnly(path),
+		StatusCode: status,
+		ErrorCode:  ErrorCode(errorCode(err, v)),
+		Duration:   time.Since(start),
+		Err:        err,
+	})
+	return err
+}
@@ -0,0 +1,55 @@
+package postmark
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOnSend(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(422)
+		w.Write([]byte(`{"ErrorCode": 300, "Message": "Invalid email request"}`))
+	}))
+	defer server.Close()
+
+	var events []SendEvent
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithRetries(1, time.Millisecond), WithOnSend(func(event SendEvent) {
+		events = append(events, event)
+	}))
+
+	if _, err := c.Send(testMail()); err == nil {
+		t.Fatalf("Expected an error for HTTP 422")
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected one event for a retried send, got %d", len(events))
+	}
+	event := events[0]
+	if event.Path != "/email" || event.StatusCode != 422 || event.ErrorCode != ErrCodeInvalidEmailRequest || event.Err == nil || event.Duration <= 0 {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	c, _ = NewClient("1234567", WithHTTPClient(NewRecorder().Client()), WithOnSend(func(event SendEvent) {
+		events = append(events, event)
+	}))
+	if _, err := c.SendBatch([]*PMMail{testMail(), testMail()}); err != nil {
+		t.Fatalf("Unexpected error sending batch: %s", err)
+	}
+	if last := events[len(events)-1]; last.Path != "/email/batch" || last.StatusCode != 200 || last.ErrorCode != 0 || last.Err != nil {
+		t.Errorf("Unexpected batch event %+v", last)
+	}
+}
+
+func TestOnSendUnset(t *testing.T) {
+	c, _ := NewClient("1234567", WithHTTPClient(NewRecorder().Client()))
+	if _, err := c.Send(testMail()); err != nil {
+		t.Errorf("Unexpected error sending without an OnSend function: %s", err)
+	}
+}
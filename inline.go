@@ -0,0 +1,113 @@
+package postmark
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Matches the quoted src attribute of an <img>
+// tag, capturing everything before the value
+// and the value in double or single quotes
+var imgSrcPattern = regexp.MustCompile(`(?i)(<img\b[^>]*?\ssrc\s*=\s*)(?:"([^"]*)"|'([^']*)')`)
+
+// Attach the local images the HTML body shows
+// inline, and point their <img> tags at the
+// attachments with cid: references. Without
+// images, every src beginning with "file:" is
+// inlined. Otherwise images maps src values,
+// as they appear in the HTML, to the files to
+// attach, and other srcs are left alone. An
+// image shown several times is attached once,
+// and http and https srcs are never inlined.
+// If any file cannot be read, the error lists
+// them all and the message is left unchanged
+func (p *PMMail) InlineImages(images map[string]string) error {
+	attached := len(p.attachments)
+	contentIDs := make(map[string]string)
+	var failed []string
+
+	body := imgSrcPattern.ReplaceAllStringFunc(p.HTMLBody, func(tag string) string {
+		match := imgSrcPattern.FindStringSubmatch(tag)
+		src, quote := match[2], `"`
+		if strings.HasPrefix(match[0][len(match[1]):], "'") {
+			src, quote = match[3], "'"
+		}
+
+		file, ok := inlineImageFile(src, images)
+		if !ok {
+			return tag
+		}
+
+		contentID, ok := contentIDs[file]
+		if !ok {
+			contentID = p.nextImageContentID()
+			if err := p.AddInlineAttachmentFile(file, contentID); err != nil {
+				failed = append(failed, err.Error())
+				contentIDs[file] = ""
+				return tag
+			}
+			contentIDs[file] = contentID
+		}
+		if contentID == "" {
+			return tag
+		}
+
+		return match[1] + quote + cid(contentID) + quote
+	})
+
+	if len(failed) > 0 {
+		clear(p.attachments[attached:])
+		p.attachments = p.attachments[:attached]
+		return fmt.Errorf("Cannot inline images in the HTML body: %s", strings.Join(failed, "; "))
+	}
+
+	p.HTMLBody = body
+	return nil
+}
+
+// Returns the file an <img> src should be
+// inlined from, or false if it should be left
+// as it is
+func inlineImageFile(src string, images map[string]string) (string, bool) {
+	lower := strings.ToLower(src)
+	if strings.HasPrefix(lower, "http:") || strings.HasPrefix(lower, "https:") {
+		return "", false
+	}
+
+	if images != nil {
+		file, ok := images[src]
+		return file, ok
+	}
+
+	if !strings.HasPrefix(lower, "file:") {
+		return "", false
+	}
+	if strings.HasPrefix(lower, "file://") {
+		u, err := url.Parse(src)
+		if err != nil || u.Path == "" {
+			return src, true
+		}
+		return u.Path, true
+	}
+	return src[len("file:"):], true
+}
+
+// Returns a content ID for an inline image that
+// no attachment already has
+func (p *PMMail) nextImageContentID() string {
+	for n := 1; ; n++ {
+		contentID := fmt.Sprintf("image%d", n)
+		taken := false
+		for _, a := range p.attachments {
+			if a.ContentID == cid(contentID) {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return contentID
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package postmark
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInlineImages(t *testing.T) {
+	logo, err := filepath.Abs("testdata/logo.png")
+	if err != nil {
+		t.Fatalf("Error finding test image: %s", err)
+	}
+
+	p := testMail()
+	p.HTMLBody = `<img src="file:testdata/logo.png"><img alt='x' src='file://` + logo + `'>` +
+		`<img src="file:testdata/logo.png"><img src="https://example.com/a.png"><img src="cid:other">`
+	if err := p.InlineImages(nil); err != nil {
+		t.Fatalf("Error inlining images: %s", err)
+	}
+
+	expected := `<img src="cid:image1"><img alt='x' src='cid:image2'>` +
+		`<img src="cid:image1"><img src="https://example.com/a.png"><img src="cid:other">`
+	if p.HTMLBody != expected {
+		t.Errorf("Unexpected HTML body %s", p.HTMLBody)
+	}
+	if len(p.attachments) != 2 {
+		t.Fatalf("Expected 2 attachments, got %d", len(p.attachments))
+	}
+	if a := p.attachments[0]; a.Name != "logo.png" || a.ContentID != "cid:image1" || a.ContentType != "image/png" {
+		t.Errorf("Unexpected attachment %+v", a)
+	}
+}
+
+func TestInlineImagesMap(t *testing.T) {
+	p := testMail()
+	if err := p.AddInlineAttachment("header.png", "image/png", "image1", []byte("png")); err != nil {
+		t.Fatalf("Error attaching inline image: %s", err)
+	}
+	p.HTMLBody = `<IMG SRC="logo.png"><img src="file:other.png"><img src="http://example.com/logo.png">`
+	images := map[string]string{
+		"logo.png":                    "testdata/logo.png",
+		"http://example.com/logo.png": "testdata/logo.png",
+	}
+	if err := p.InlineImages(images); err != nil {
+		t.Fatalf("Error inlining images: %s", err)
+	}
+
+	expected := `<IMG SRC="cid:image2"><img src="file:other.png"><img src="http://example.com/logo.png">`
+	if p.HTMLBody != expected {
+		t.Errorf("Unexpected HTML body %s", p.HTMLBody)
+	}
+	if len(p.attachments) != 2 {
+		t.Errorf("Expected 2 attachments, got %d", len(p.attachments))
+	}
+}
+
+func TestInlineImagesMissing(t *testing.T) {
+	dir := t.TempDir()
+	p := testMail()
+	html := `<img src="file:testdata/logo.png"><img src="file:` + filepath.Join(dir, "a.png") + `">` +
+		`<img src="file:` + filepath.Join(dir, "b.png") + `">`
+	p.HTMLBody = html
+
+	err := p.InlineImages(nil)
+	if err == nil {
+		t.Fatalf("Expected an error for missing images")
+	}
+	for _, name := range []string{"a.png", "b.png"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected the error to name %s: %s", name, err)
+		}
+	}
+	if p.HTMLBody != html || len(p.attachments) != 0 {
+		t.Errorf("Expected the message to be unchanged, got %s with %d attachments", p.HTMLBody, len(p.attachments))
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.png"), []byte("a"), 0600); err != nil {
+		t.Fatalf("Error writing image: %s", err)
+	}
+	if err := p.InlineImages(nil); err == nil || strings.Contains(err.Error(), "a.png") {
+		t.Errorf("Expected only b.png to fail, got %v", err)
+	}
+}
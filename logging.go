@@ -0,0 +1,91 @@
+package postmark
+
+import (
+	"context"
+	"log/slog"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Logs retries, rate limiter waits and failed
+// requests to logger: failures that will be
+// retried at Warn, and those that won't at
+// Error. Entries name a message's Tag and the
+// domains it is sent to, but never the full
+// addresses or the server token. Without a
+// logger nothing is logged
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// Returns a copy of the client whose log
+// entries carry the given attributes
+func (c *Client) withLogAttrs(args ...any) *Client {
+	if c.logger == nil {
+		return c
+	}
+
+	derived := *c
+	derived.logger = c.logger.With(args...)
+	return &derived
+}
+
+// Returns a copy of the client whose log
+// entries describe m
+func (c *Client) withMessageLog(m *PMMail) *Client {
+	if c.logger == nil {
+		return c
+	}
+	return c.withLogAttrs(slog.String("tag", m.Tag), slog.Any("domains", recipientDomains(m)))
+}
+
+func (c *Client) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.Log(ctx, level, msg, args...)
+}
+
+// Logs a rate limiter wait, if there was one
+// long enough to mention
+func (c *Client) logLimiterWait(ctx context.Context, wait time.Duration) {
+	if wait >= time.Millisecond {
+		c.log(ctx, slog.LevelDebug, "Postmark request waited for the rate limiter", slog.Duration("wait", wait))
+	}
+}
+
+// Returns the domains of all of a message's
+// recipients, sorted and deduplicated
+func recipientDomains(m *PMMail) []string {
+	seen := make(map[string]bool)
+	var domains []string
+	for _, list := range []string{m.toList(), m.ccList(), m.bccList()} {
+		for _, addr := range splitAddressList(list) {
+			domain := addressDomain(addr)
+			if domain != "" && !seen[domain] {
+				seen[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Returns the domain of an address, such as
+// "example.com" for "Dave <dave@example.com>"
+func addressDomain(addr string) string {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		addr = parsed.Address
+	}
+	at := strings.LastIndex(addr, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(addr[at+1:]), ">"))
+}
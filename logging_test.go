@@ -0,0 +1,59 @@
+package postmark
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(500)
+			return
+		}
+		w.WriteHeader(422)
+		w.Write([]byte(`{"ErrorCode": 300, "Message": "Invalid email request"}`))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, nil))
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithRetries(1, time.Millisecond), WithLogger(logger))
+
+	p := testMail()
+	p.Tag = "welcome"
+	if _, err := c.Send(p); err == nil {
+		t.Fatalf("Expected an error for HTTP 422")
+	}
+
+	logged := out.String()
+	for _, expected := range []string{"level=WARN msg=\"Retrying Postmark request\"", "level=ERROR msg=\"Postmark request failed\"", "tag=welcome", "flyclops.com"} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("Expected %q in the log:\n%s", expected, logged)
+		}
+	}
+	for _, secret := range []string{"1234567", "dave@flyclops.com"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("Expected %q not to be logged:\n%s", secret, logged)
+		}
+	}
+}
+
+func TestRecipientDomains(t *testing.T) {
+	p := testMail()
+	p.CC = "someone@Example.com, other@example.com"
+	p.AddBCC("audit@archive.example.org")
+
+	expected := []string{"archive.example.org", "example.com", "flyclops.com"}
+	if domains := recipientDomains(p); !reflect.DeepEqual(domains, expected) {
+		t.Errorf("Expected domains %v, got %v", expected, domains)
+	}
+}
@@ -0,0 +1,24 @@
+package postmark
+
+import "context"
+
+// Sends single messages, so code that sends
+// e-mail can depend on this rather than the
+// concrete Client and be given a fake in tests
+type Mailer interface {
+	SendContext(ctx context.Context, m *PMMail) (*Reply, error)
+	SendWithTemplateContext(ctx context.Context, m *PMMail) (*Reply, error)
+}
+
+// Sends batches of messages, as Client's
+// SendBatchContext and
+// SendBatchWithTemplatesContext do
+type BatchMailer interface {
+	SendBatchContext(ctx context.Context, messages []*PMMail) ([]Reply, error)
+	SendBatchWithTemplatesContext(ctx context.Context, messages []*PMMail) ([]Reply, error)
+}
+
+var (
+	_ Mailer      = (*Client)(nil)
+	_ BatchMailer = (*Client)(nil)
+)
@@ -0,0 +1,81 @@
+package postmark
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Renders Markdown to HTML, for SetMarkdownBody.
+// The package doesn't include a Markdown
+// implementation, so wrap the one you use
+type MarkdownRenderer func(markdown string) (string, error)
+
+// Renders the bodies set by SetMarkdownBody
+// with render
+func WithMarkdownRenderer(render MarkdownRenderer) Option {
+	return func(c *Client) error {
+		if render == nil {
+			return fmt.Errorf("Cannot create a client with a nil Markdown renderer")
+		}
+		c.markdown = render
+		return nil
+	}
+}
+
+// Set the HTML body to markdown rendered by the
+// client's WithMarkdownRenderer, and the text
+// body to the Markdown itself, which reads well
+// as plain text. A Message literal has no
+// client, so it keeps the Markdown and the
+// client it is sent with renders it, unless
+// HTMLBody is set first. Without a renderer,
+// or if rendering fails, the bodies are left
+// alone and an error is returned
+func (p *PMMail) SetMarkdownBody(markdown string) error {
+	if p.client == nil {
+		p.markdown = markdown
+		p.HTMLBody = ""
+		p.TextBody = strings.TrimSpace(markdown)
+		return nil
+	}
+
+	html, err := renderMarkdown(p.client, markdown)
+	if err != nil {
+		return err
+	}
+
+	p.markdown = ""
+	p.HTMLBody = html
+	p.TextBody = strings.TrimSpace(markdown)
+
+	return nil
+}
+
+// Reports whether p still has Markdown from
+// SetMarkdownBody for the sending client to
+// render
+func (p *PMMail) pendingMarkdown() bool {
+	return p.markdown != "" && p.HTMLBody == ""
+}
+
+// Returns the HTML body to send p with using c,
+// rendering any Markdown SetMarkdownBody left
+// for the sending client
+func (p *PMMail) htmlBody(c *Client) (string, error) {
+	if !p.pendingMarkdown() {
+		return p.HTMLBody, nil
+	}
+	return renderMarkdown(c, p.markdown)
+}
+
+func renderMarkdown(c *Client, markdown string) (string, error) {
+	if c.markdown == nil {
+		return "", fmt.Errorf("Cannot render Markdown without a renderer (WithMarkdownRenderer option)")
+	}
+
+	html, err := c.markdown(markdown)
+	if err != nil {
+		return "", fmt.Errorf("Cannot render Markdown body: %w", err)
+	}
+	return html, nil
+}
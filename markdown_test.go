@@ -0,0 +1,78 @@
+package postmark
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSetMarkdownBody(t *testing.T) {
+	p := testMail()
+	if err := p.SetMarkdownBody("# Hello"); err == nil {
+		t.Errorf("Expected an error without a renderer")
+	}
+
+	render := func(markdown string) (string, error) {
+		if strings.Contains(markdown, "broken") {
+			return "", errors.New("parse error")
+		}
+		return "<h1>" + strings.TrimPrefix(markdown, "# ") + "</h1>", nil
+	}
+	p = CreatePMMail("1234567", WithMarkdownRenderer(render))
+	if err := p.SetMarkdownBody("# Hello\n"); err != nil {
+		t.Fatalf("Unexpected error rendering Markdown: %s", err)
+	}
+	if p.HTMLBody != "<h1>Hello\n</h1>" || p.TextBody != "# Hello" {
+		t.Errorf("Unexpected bodies %q and %q", p.HTMLBody, p.TextBody)
+	}
+
+	if err := p.SetMarkdownBody("broken"); err == nil || p.TextBody != "# Hello" {
+		t.Errorf("Expected a render error to leave the bodies alone, got %v", err)
+	}
+}
+
+func TestSetMarkdownBodyWithoutRenderer(t *testing.T) {
+	render := func(markdown string) (string, error) {
+		return "<h1>" + strings.TrimPrefix(markdown, "# ") + "</h1>", nil
+	}
+	recorder := NewRecorder()
+	c, _ := NewClient("1234567", WithHTTPClient(recorder.Client()), WithMarkdownRenderer(render))
+
+	m := &Message{Sender: "dave@flyclops.com", To: "someone@example.com", Subject: "Hello"}
+	if err := m.SetMarkdownBody("# Hello"); err != nil {
+		t.Fatalf("Unexpected error setting Markdown: %s", err)
+	}
+	if m.HTMLBody != "" || m.TextBody != "# Hello" {
+		t.Errorf("Expected the Markdown kept for the sending client, got %q and %q", m.HTMLBody, m.TextBody)
+	}
+	if _, err := c.Send(m); err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+	if _, err := c.SendBatch([]*PMMail{m}); err != nil {
+		t.Fatalf("Unexpected error sending a batch: %s", err)
+	}
+	for _, sent := range recorder.Sent() {
+		if sent.HtmlBody != "<h1>Hello</h1>" || sent.TextBody != "# Hello" {
+			t.Errorf("Expected the sending client to render the Markdown, got %q and %q", sent.HtmlBody, sent.TextBody)
+		}
+	}
+	if m.HTMLBody != "" {
+		t.Errorf("Expected sending to leave the message alone, got %q", m.HTMLBody)
+	}
+
+	plain, _ := NewClient("1234567", WithHTTPClient(recorder.Client()))
+	if err := m.checkValues(plain); err == nil || !strings.Contains(err.Error(), "WithMarkdownRenderer") {
+		t.Errorf("Expected checking with a client without a renderer to fail, got %v", err)
+	}
+	if err := m.checkValues(c); err != nil {
+		t.Errorf("Unexpected error checking with a renderer: %s", err)
+	}
+	if _, err := plain.Send(m); err == nil || !strings.Contains(err.Error(), "WithMarkdownRenderer") {
+		t.Errorf("Expected an error sending with a client without a renderer, got %v", err)
+	}
+
+	m.HTMLBody = "<p>Hello</p>"
+	if _, err := plain.Send(m); err != nil {
+		t.Errorf("Expected an HTML body set later to be sent as it is, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package postmark
+
+import (
+	"errors"
+	"time"
+)
+
+// Receives observations of a Client's API calls,
+// for exporting to a metrics system. Methods are
+// called from the sending goroutine, so must be
+// safe for concurrent use when the client is
+// shared
+type Metrics interface {
+	// Called after every HTTP request, including
+	// each retry, with how long it took and the
+	// HTTP status. statusCode is zero when no
+	// response was received. errorCode is the
+	// Postmark error code of a single send, or
+	// zero when there was none
+	ObserveSend(duration time.Duration, statusCode int, errorCode int)
+
+	// Called once for each message Postmark replies
+	// to, whether sent alone or in a batch and
+	// however many attempts it took, with the
+	// message's error code, which is zero when the
+	// message was accepted
+	ObserveMessage(errorCode int)
+}
+
+// Reports every API call the client makes to m.
+// By default nothing is reported
+func WithMetrics(m Metrics) Option {
+	return func(c *Client) error {
+		c.metrics = m
+		return nil
+	}
+}
+
+// Reports an API call to the client's Metrics,
+// if it has one. v is the call's decoded reply
+func (c *Client) observe(duration time.Duration, statusCode int, err error, v interface{}) {
+	if c.metrics == nil {
+		return
+	}
+	c.metrics.ObserveSend(duration, statusCode, errorCode(err, v))
+}
+
+// Reports the outcome of each message sent to
+// the client's Metrics, once retries and any
+// token failover are over, so a message is
+// counted once however many requests it took.
+// v is the send's *Reply or *[]Reply
+func (c *Client) observeMessages(err error, v interface{}) {
+	if c.metrics == nil {
+		return
+	}
+
+	switch v := v.(type) {
+	case *Reply:
+		// A single send Postmark rejects, such as
+		// with HTTP 422, is still a message outcome
+		var pmErr *PostmarkError
+		if errors.As(err, &pmErr) {
+			c.metrics.ObserveMessage(int(pmErr.ErrorCode))
+		} else if err == nil && v != nil {
+			c.metrics.ObserveMessage(v.ErrorCode)
+		}
+	case *[]Reply:
+		if err != nil {
+			return
+		}
+		for _, reply := range *v {
+			c.metrics.ObserveMessage(reply.ErrorCode)
+		}
+	}
+}
+
+// Returns Postmark's error code for a call,
+// from its error or its single decoded reply
+func errorCode(err error, v interface{}) int {
+	var pmErr *PostmarkError
+	if errors.As(err, &pmErr) {
+		return int(pmErr.ErrorCode)
+	}
+	if reply, ok := v.(*Reply); ok && err == nil {
+		return reply.ErrorCode
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package postmark
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testMetrics struct {
+	mu       sync.Mutex
+	sends    [][2]int
+	messages []int
+}
+
+func (m *testMetrics) ObserveSend(duration time.Duration, statusCode int, errorCode int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sends = append(m.sends, [2]int{statusCode, errorCode})
+}
+
+func (m *testMetrics) ObserveMessage(errorCode int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, errorCode)
+}
+
+func TestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/email":
+			w.WriteHeader(422)
+			w.Write([]byte(`{"ErrorCode": 300, "Message": "Invalid email request"}`))
+		case "/email/batch":
+			w.Write([]byte(`[{"ErrorCode": 0}, {"ErrorCode": 406}]`))
+		}
+	}))
+	defer server.Close()
+
+	metrics := &testMetrics{}
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithMetrics(metrics))
+
+	if _, err := c.Send(testMail()); err == nil {
+		t.Fatalf("Expected an error for HTTP 422")
+	}
+	if len(metrics.sends) != 1 || metrics.sends[0] != [2]int{422, 300} {
+		t.Errorf("Expected a 422 observation with error code 300, got %v", metrics.sends)
+	}
+	if len(metrics.messages) != 1 || metrics.messages[0] != 300 {
+		t.Errorf("Expected the rejected message's outcome with error code 300, got %v", metrics.messages)
+	}
+
+	b := c.NewBatch()
+	b.Add(testMail())
+	b.Add(testMail())
+	if _, err := b.Send(); err != nil {
+		t.Fatalf("Unexpected error sending batch: %s", err)
+	}
+	if len(metrics.sends) != 2 || metrics.sends[1] != [2]int{200, 0} {
+		t.Errorf("Expected one observation for the batch request, got %v", metrics.sends)
+	}
+	if len(metrics.messages) != 3 || metrics.messages[1] != 0 || metrics.messages[2] != 406 {
+		t.Errorf("Expected an outcome per batch message, got %v", metrics.messages)
+	}
+}
+
+func TestMetricsRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(500)
+			w.Write([]byte(`{"ErrorCode": 1000, "Message": "Server error"}`))
+			return
+		}
+		w.Write([]byte(`{"ErrorCode": 0, "MessageID": "abc-123"}`))
+	}))
+	defer server.Close()
+
+	metrics := &testMetrics{}
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithMetrics(metrics), WithRetries(1, time.Millisecond))
+	if _, err := c.Send(testMail()); err != nil {
+		t.Fatalf("Unexpected error after a retry: %s", err)
+	}
+	if len(metrics.sends) != 2 || metrics.sends[0] != [2]int{500, 1000} || metrics.sends[1] != [2]int{200, 0} {
+		t.Errorf("Expected an observation per attempt, got %v", metrics.sends)
+	}
+	if len(metrics.messages) != 1 || metrics.messages[0] != 0 {
+		t.Errorf("Expected one outcome for the delivered message, got %v", metrics.messages)
+	}
+}
@@ -3,26 +3,81 @@ package postmark
 
 import (
 	"bytes"
-	"encoding/base64"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"mime"
 	"net/http"
-	"os"
-	"path"
+	"sort"
+	"strings"
+	"time"
 )
 
-const __POSTMARK_URL__ string = "https://api.postmarkapp.com/email"
+const __POSTMARK_URL__ string = "https://api.postmarkapp.com"
+const __POSTMARK_EMAIL_PATH__ string = "/email"
+const __POSTMARK_TEMPLATE_PATH__ string = "/email/withTemplate"
 const __VERSION__ string = "0.1"
 
+// Postmark's limits on message metadata
+const (
+	MaxMetadataEntries     int = 10
+	MaxMetadataKeyLength   int = 20
+	MaxMetadataValueLength int = 80
+)
+
+// The longest subject sent unless a message's
+// MaxSubjectLength says otherwise, in bytes,
+// which is RFC 5322's limit on a header line
+const DefaultMaxSubjectLength int = 998
+
+// The link tracking modes Postmark supports
+// for a message's TrackLinks field
+type LinkTracking string
+
+const (
+	LinkTrackingNone        LinkTracking = "None"
+	LinkTrackingHTMLAndText LinkTracking = "HtmlAndText"
+	LinkTrackingHTMLOnly    LinkTracking = "HtmlOnly"
+	LinkTrackingTextOnly    LinkTracking = "TextOnly"
+)
+
+// The IDs of the message streams every Postmark
+// server starts with, for a message's
+// MessageStream field
+const (
+	MessageStreamTransactional string = "outbound"
+	MessageStreamBroadcast     string = "broadcast"
+)
+
+// An e-mail to send through Postmark. A PMMail
+// is not safe for concurrent mutation, but once
+// built it may be sent from many goroutines at
+// once, as sending only reads it. To send
+// variations concurrently, give each goroutine
+// its own Clone, or its own message sent with a
+// shared Client
 type PMMail struct {
-	userAgent string
-	apiKey    string
+	client      *Client
+	clientErr   error
+	timeout     time.Duration
+	retryPolicy *retryPolicy
 
-	customHeaders []header
+	// Markdown from SetMarkdownBody for the
+	// sending client to render
+	markdown string
+
+	customHeaders []Header
 	attachments   []attachment
+	recipients    []string
+	cc            []string
+	bcc           []string
+
+	// The base URL of the Postmark API, such as an
+	// httptest.Server's URL in tests, used only by
+	// the message's own Send. When empty, or when
+	// sent by Client.Send or in a batch, the
+	// client's endpoint is used
+	Endpoint string
 
 	Sender   string
 	ReplyTo  string
@@ -33,17 +88,116 @@ type PMMail struct {
 	Tag      string
 	HTMLBody string
 	TextBody string
+
+	// The Postmark message stream to send through,
+	// such as MessageStreamBroadcast. Empty uses
+	// the server's default transactional stream
+	MessageStream string
+
+	// Whether to remove repeated addresses from
+	// the recipients before sending, ignoring
+	// case and display names. An address in To
+	// is dropped from CC and BCC, and one in CC
+	// from BCC. See DuplicateRecipients
+	DedupeRecipients bool
+
+	// Whether Postmark should track opens of this
+	// message. Nil omits TrackOpens from the JSON,
+	// leaving the server's default, while Bool(false)
+	// sends it to turn tracking off
+	TrackOpens *bool
+
+	// Which links Postmark should rewrite to track
+	// clicks. Empty omits TrackLinks from the JSON,
+	// leaving the server's default, while
+	// LinkTrackingNone sends it to turn tracking off
+	TrackLinks LinkTracking
+
+	// Key/value pairs Postmark returns on webhooks
+	// and the messages API. See AddMetadata
+	Metadata map[string]string
+
+	// The limit on the total size of the message's
+	// attachments. Defaults to Postmark's 10MB
+	// limit, DefaultMaxAttachmentBytes, when zero
+	MaxAttachmentBytes int64
+
+	// The limit on the size of the whole message,
+	// its bodies and base64 encoded attachments.
+	// Defaults to Postmark's 10MB limit,
+	// DefaultMaxMessageBytes, when zero
+	MaxMessageBytes int64
+
+	// The limit on the size of a download by
+	// AddAttachmentFromURL, which also can't
+	// exceed MaxAttachmentBytes. Defaults to
+	// DefaultMaxDownloadBytes when zero
+	MaxDownloadBytes int64
+
+	// The limit on the length of Subject in bytes.
+	// Defaults to DefaultMaxSubjectLength when zero
+	MaxSubjectLength int
+
+	// Set one of TemplateID or TemplateAlias to send
+	// with a Postmark template via SendWithTemplate.
+	// The subject and bodies then come from the
+	// template, rendered with TemplateModel
+	TemplateID    int
+	TemplateAlias string
+	TemplateModel map[string]interface{}
+
+	// Whether Postmark should inline the template's
+	// CSS. Nil leaves Postmark's default (true)
+	InlineCSS *bool
 }
 
-type header struct {
+// The content of an e-mail, for sending with
+// a Client. A Message built as a literal rather
+// than by CreatePMMail or NewMail carries no
+// API key of its own, so it must be sent with
+// Client.Send; its own Send method returns an
+// error
+type Message = PMMail
+
+// A custom header of an e-mail, sent in its
+// JSON packet
+type Header struct {
 	Name  string
 	Value string
 }
 
-type attachment struct {
-	Name        string
-	Content     string
-	ContentType string
+// The JSON Postmark expects for a message, with
+// fields in the order of its API documentation.
+// Optional fields are omitted when empty. From
+// is always sent; To and Subject are omitted
+// too, as To is optional beside Cc and Bcc and
+// a template send's subject is the template's.
+// A new Postmark field needs only a tagged
+// field here, set in createJsonMessagePacket
+type messagePayload struct {
+	From          string
+	To            string `json:",omitempty"`
+	Cc            string `json:",omitempty"`
+	Bcc           string `json:",omitempty"`
+	ReplyTo       string `json:",omitempty"`
+	Subject       string `json:",omitempty"`
+	Tag           string `json:",omitempty"`
+	HtmlBody      string `json:",omitempty"`
+	TextBody      string `json:",omitempty"`
+	TemplateId    int    `json:",omitempty"`
+	TemplateAlias string `json:",omitempty"`
+
+	// An interface, so that a template's empty
+	// model is sent rather than omitted
+	TemplateModel interface{} `json:",omitempty"`
+
+	InlineCss     *bool             `json:",omitempty"`
+	Headers       []Header          `json:",omitempty"`
+	TrackOpens    *bool             `json:",omitempty"`
+	TrackLinks    LinkTracking      `json:",omitempty"`
+	Metadata      map[string]string `json:",omitempty"`
+	Attachments   []attachment      `json:",omitempty"`
+	MessageStream string            `json:",omitempty"`
 }
 
 type Reply struct {
@@ -52,198 +206,541 @@ type Reply struct {
 	MessageID   string
 	SubmittedAt string
 	To          string
+
+	// Set when the message was sent by a sandbox
+	// client, and so was never delivered
+	Sandbox bool `json:"-"`
+
+	// Which of the WithTokens tokens the message
+	// was sent with, as an index so the token
+	// itself isn't passed around
+	TokenIndex int `json:"-"`
+
+	// The HTTP status and headers of the response
+	// the reply came in, such as the X-Request-Id
+	// Postmark support asks for. The replies in a
+	// batch share its response
+	StatusCode int         `json:"-"`
+	Header     http.Header `json:"-"`
+}
+
+// Returns the time Postmark accepted the
+// message, parsed from SubmittedAt
+func (r *Reply) SubmittedTime() (time.Time, error) {
+	if r.SubmittedAt == "" {
+		return time.Time{}, fmt.Errorf("Reply has no submission time (.SubmittedAt field)")
+	}
+	return time.Parse(time.RFC3339Nano, r.SubmittedAt)
+}
+
+// Returns a *PostmarkError when Postmark
+// rejected the message the reply is for, or
+// nil when it was accepted. This tells a
+// message rejected within a batch apart from
+// a batch that couldn't be sent at all
+func (r *Reply) Err() error {
+	if r.ErrorCode == 0 {
+		return nil
+	}
+	return &PostmarkError{StatusCode: http.StatusOK, ErrorCode: ErrorCode(r.ErrorCode), Message: r.Message}
+}
+
+// Sets the reply's ErrorCode and Message from
+// err, if it is a PostmarkError
+func (r *Reply) setError(err error) {
+	var pmErr *PostmarkError
+	if errors.As(err, &pmErr) {
+		r.ErrorCode = int(pmErr.ErrorCode)
+		r.Message = pmErr.Message
+	}
+}
+
+// Returns a pointer to v, for the optional
+// boolean fields on PMMail
+func Bool(v bool) *bool {
+	return &v
 }
 
 // Create a new PMMail struct with
 // an Postmark API key, and return a
-// pointer to it
-func CreatePMMail(apikey string) *PMMail {
-	pmmail := &PMMail{apiKey: apikey}
-	pmmail.userAgent = fmt.Sprintf("Go (Go postmark package library version %f)", __VERSION__)
-
-	return pmmail
+// pointer to it. The message is sent
+// with a Client for the key configured
+// by any options, such as WithTimeout.
+// An invalid option is reported by Send
+func CreatePMMail(apikey string, opts ...Option) *PMMail {
+	c, err := NewClient(apikey, opts...)
+	if err != nil {
+		return &PMMail{client: newClient(apikey), clientErr: err}
+	}
+	return &PMMail{client: c}
 }
 
-// Add a custom header to the email message
+// Add a custom header to the email message,
+// alongside any with the same name, for
+// headers that may be repeated. See SetHeader
 func (p *PMMail) AddCustomHeader(name, value string) {
-	h := header{
+	h := Header{
 		Name:  name,
 		Value: value,
 	}
 	p.customHeaders = append(p.customHeaders, h)
 }
 
-// Add a file attachment by file path
-// Most shamefully inspired by
-// https://github.com/gcmurphy/postmark/blob/master/message.go
-func (p *PMMail) AddAttachment(file string) error {
-	fileInfo, err := os.Stat(file)
-	if err != nil {
-		return err
+// Set a custom header, replacing every header
+// already added with the same name, ignoring
+// case. The header keeps the place of the
+// first one it replaces
+func (p *PMMail) SetHeader(name, value string) {
+	for i, h := range p.customHeaders {
+		if strings.EqualFold(h.Name, name) {
+			p.customHeaders[i] = Header{Name: name, Value: value}
+			p.removeHeaders(name, i+1)
+			return
+		}
 	}
-	if fileInfo.Size() > int64(10e6) {
-		return fmt.Errorf("File size %d exceeds 10MB limit.", fileInfo.Size())
+	p.AddCustomHeader(name, value)
+}
+
+// Returns the value of the first custom header
+// with the given name, ignoring case, or "" if
+// there is none
+func (p *PMMail) GetHeader(name string) string {
+	for _, h := range p.customHeaders {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
 	}
+	return ""
+}
 
-	fileHandle, err := os.Open(file)
-	if err != nil {
-		return err
+// Removes every custom header with the given
+// name, ignoring case, reporting whether there
+// were any
+func (p *PMMail) RemoveHeader(name string) bool {
+	n := len(p.customHeaders)
+	p.removeHeaders(name, 0)
+	return len(p.customHeaders) < n
+}
+
+// Returns a copy of the custom headers, in the
+// order they were added
+func (p *PMMail) Headers() []Header {
+	return append([]Header(nil), p.customHeaders...)
+}
+
+// Removes the custom headers named name from
+// index from onwards
+func (p *PMMail) removeHeaders(name string, from int) {
+	kept := p.customHeaders[:from]
+	for _, h := range p.customHeaders[from:] {
+		if !strings.EqualFold(h.Name, name) {
+			kept = append(kept, h)
+		}
 	}
+	clear(p.customHeaders[len(kept):])
+	p.customHeaders = kept
+}
 
-	content, err := ioutil.ReadAll(fileHandle)
-	if err != nil {
-		fileHandle.Close()
-		return err
-	} else {
-		fileHandle.Close()
+// Add a metadata entry to the email message.
+// Postmark allows up to 10 entries, with keys of
+// up to 20 and values of up to 80 characters
+func (p *PMMail) AddMetadata(key, value string) {
+	if p.Metadata == nil {
+		p.Metadata = make(map[string]string)
 	}
+	p.Metadata[key] = value
+}
+
+// Set a variable in the template model the
+// message's template is rendered with
+func (p *PMMail) SetTemplateModel(key string, value interface{}) {
+	if p.TemplateModel == nil {
+		p.TemplateModel = make(map[string]interface{})
+	}
+	p.TemplateModel[key] = value
+}
 
-	mimeType := mime.TypeByExtension(path.Ext(file))
-	if len(mimeType) == 0 {
-		mimeType = "application/octet-stream"
+// Returns a copy of the message, for sending
+// variations of one message without rebuilding
+// it. Headers, attachments, recipients and
+// metadata are copied, so changing the clone
+// leaves p alone. The template model is copied
+// one level deep, and the client is shared
+func (p *PMMail) Clone() *PMMail {
+	clone := *p
+	clone.customHeaders = append([]Header(nil), p.customHeaders...)
+	clone.attachments = append([]attachment(nil), p.attachments...)
+	clone.recipients = append([]string(nil), p.recipients...)
+	clone.cc = append([]string(nil), p.cc...)
+	clone.bcc = append([]string(nil), p.bcc...)
+
+	if p.Metadata != nil {
+		clone.Metadata = make(map[string]string, len(p.Metadata))
+		for key, value := range p.Metadata {
+			clone.Metadata[key] = value
+		}
 	}
+	if p.TemplateModel != nil {
+		clone.TemplateModel = make(map[string]interface{}, len(p.TemplateModel))
+		for key, value := range p.TemplateModel {
+			clone.TemplateModel[key] = value
+		}
+	}
+	if p.TrackOpens != nil {
+		clone.TrackOpens = Bool(*p.TrackOpens)
+	}
+	if p.InlineCSS != nil {
+		clone.InlineCSS = Bool(*p.InlineCSS)
+	}
+
+	return &clone
+}
 
-	a := attachment{
-		Name:        fileInfo.Name(),
-		Content:     base64.StdEncoding.EncodeToString(content),
-		ContentType: mimeType,
+// Clears the message for reuse with another
+// send. Only the client, and so its API key
+// and options, any SetTimeout timeout and
+// SetRetryPolicy policy, Endpoint and the
+// MaxAttachmentBytes, MaxMessageBytes,
+// MaxDownloadBytes and MaxSubjectLength
+// limits survive. Every other field is
+// zeroed, and headers, attachments and added
+// recipients are removed
+func (p *PMMail) Reset() {
+	clear(p.attachments)
+	*p = PMMail{
+		client:             p.client,
+		clientErr:          p.clientErr,
+		timeout:            p.timeout,
+		retryPolicy:        p.retryPolicy,
+		customHeaders:      p.customHeaders[:0],
+		attachments:        p.attachments[:0],
+		recipients:         p.recipients[:0],
+		cc:                 p.cc[:0],
+		bcc:                p.bcc[:0],
+		Endpoint:           p.Endpoint,
+		MaxAttachmentBytes: p.MaxAttachmentBytes,
+		MaxMessageBytes:    p.MaxMessageBytes,
+		MaxDownloadBytes:   p.MaxDownloadBytes,
+		MaxSubjectLength:   p.MaxSubjectLength,
 	}
-	p.attachments = append(p.attachments, a)
+}
 
-	return nil
+// Checks the message as Send would before
+// contacting Postmark, returning the first
+// problem found: missing fields, addresses that
+// don't parse, and the recipient, metadata and
+// attachment limits. Nothing is sent, so this
+// needs no network access or API key
+func (p *PMMail) Validate() error {
+	return p.checkValues(p.sender())
 }
 
-func (p *PMMail) checkValues() error {
+// Checks the message as it would be sent by c
+func (p *PMMail) checkValues(c *Client) error {
 	if p.Sender == "" {
 		return fmt.Errorf("Cannot send e-mail without a sender (.Sender field)")
 	}
-	if p.To == "" {
-		return fmt.Errorf("Cannot send e-mail without recipient (.To field)")
+	if p.toList() == "" && p.ccList() == "" && p.bccList() == "" {
+		return fmt.Errorf("Cannot send e-mail without a recipient (.To, .CC or .BCC field)")
+	}
+	if err := p.checkRecipients(); err != nil {
+		return err
+	}
+	if !c.skipAddressCheck {
+		if err := p.checkAddresses(); err != nil {
+			return err
+		}
+	}
+	if err := p.checkMetadata(); err != nil {
+		return err
+	}
+	if err := p.checkAttachments(); err != nil {
+		return err
+	}
+	if err := p.checkHeaders(); err != nil {
+		return err
+	}
+	switch p.TrackLinks {
+	case "", LinkTrackingNone, LinkTrackingHTMLAndText, LinkTrackingHTMLOnly, LinkTrackingTextOnly:
+	default:
+		return fmt.Errorf("Cannot send e-mail with unknown link tracking mode %q (.TrackLinks field)", p.TrackLinks)
+	}
+	if p.TemplateID != 0 && p.TemplateAlias != "" {
+		return fmt.Errorf("Cannot send e-mail with both a template ID and alias (.TemplateID and .TemplateAlias fields)")
+	}
+	if p.usesTemplate() {
+		return nil
 	}
 	if p.Subject == "" {
 		return fmt.Errorf("Cannot send e-mail without a subject (.Subject field)")
 	}
+	if strings.ContainsAny(p.Subject, "\r\n") {
+		return fmt.Errorf("Cannot send e-mail with a line break in the subject (.Subject field)")
+	}
+	if limit := p.maxSubjectLength(); len(p.Subject) > limit {
+		return fmt.Errorf("Cannot send e-mail with a %d byte subject, the limit is %d (.Subject field)", len(p.Subject), limit)
+	}
 	if p.HTMLBody == "" && p.TextBody == "" {
 		return fmt.Errorf("Cannot send email without an HTML body, text body or both")
 	}
+	if p.pendingMarkdown() && c.markdown == nil {
+		return fmt.Errorf("Cannot render Markdown without a renderer (WithMarkdownRenderer option)")
+	}
 
 	return nil
 }
 
-func (p *PMMail) createJsonMessagePacket() ([]byte, error) {
-	if err := p.checkValues(); err != nil {
-		return []byte{}, err
+func (p *PMMail) checkMetadata() error {
+	if n := len(p.Metadata); n > MaxMetadataEntries {
+		return fmt.Errorf("Cannot send e-mail with %d metadata entries, the limit is %d (.Metadata field)", n, MaxMetadataEntries)
 	}
-
-	json_interface := map[string]interface{}{
-		"From":    p.Sender,
-		"To":      p.To,
-		"Subject": p.Subject,
+	// Checked in key order, so the same metadata
+	// always fails with the same error
+	keys := make([]string, 0, len(p.Metadata))
+	for key := range p.Metadata {
+		keys = append(keys, key)
 	}
-
-	if p.ReplyTo != "" {
-		json_interface["ReplyTo"] = p.ReplyTo
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := p.Metadata[key]
+		if key == "" {
+			return fmt.Errorf("Cannot send e-mail with an empty metadata key (.Metadata field)")
+		}
+		if n := len([]rune(key)); n > MaxMetadataKeyLength {
+			return fmt.Errorf("Metadata key %q is %d characters, the limit is %d (.Metadata field)", key, n, MaxMetadataKeyLength)
+		}
+		if n := len([]rune(value)); n > MaxMetadataValueLength {
+			return fmt.Errorf("Metadata value for %q is %d characters, the limit is %d (.Metadata field)", key, n, MaxMetadataValueLength)
+		}
 	}
 
-	if p.CC != "" {
-		json_interface["Cc"] = p.CC
-	}
+	return nil
+}
 
-	if p.BCC != "" {
-		json_interface["Bcc"] = p.BCC
+func (p *PMMail) maxSubjectLength() int {
+	if p.MaxSubjectLength > 0 {
+		return p.MaxSubjectLength
 	}
+	return DefaultMaxSubjectLength
+}
 
-	if p.Tag != "" {
-		json_interface["Tag"] = p.Tag
+// Checks that custom header names and values
+// can't break out of their header, as a line
+// break would let them inject others
+func (p *PMMail) checkHeaders() error {
+	for _, h := range p.customHeaders {
+		if h.Name == "" || strings.ContainsAny(h.Name, "\r\n: ") {
+			return fmt.Errorf("Cannot send e-mail with invalid custom header name %q", h.Name)
+		}
+		if strings.ContainsAny(h.Value, "\r\n") {
+			return fmt.Errorf("Cannot send e-mail with a line break in custom header %q", h.Name)
+		}
 	}
+	return nil
+}
 
-	if p.HTMLBody != "" {
-		json_interface["HtmlBody"] = p.HTMLBody
-	}
+func (p *PMMail) usesTemplate() bool {
+	return p.TemplateID != 0 || p.TemplateAlias != ""
+}
 
-	if p.TextBody != "" {
-		json_interface["TextBody"] = p.TextBody
+// Packs the message as Postmark's JSON, encoded
+// as the client c is configured to
+func (p *PMMail) createJsonMessagePacket(c *Client) ([]byte, error) {
+	if err := p.checkValues(c); err != nil {
+		return []byte{}, err
 	}
 
-	if i := len(p.attachments); i > 0 {
-		json_interface["Attachments"] = p.attachments
+	payload := messagePayload{
+		From:          p.Sender,
+		To:            p.toList(),
+		Cc:            p.ccList(),
+		Bcc:           p.bccList(),
+		ReplyTo:       p.ReplyTo,
+		Tag:           p.Tag,
+		Headers:       p.customHeaders,
+		TrackOpens:    p.TrackOpens,
+		TrackLinks:    p.TrackLinks,
+		Metadata:      p.Metadata,
+		Attachments:   p.attachments,
+		MessageStream: p.MessageStream,
 	}
 
-	if i := len(p.customHeaders); i > 0 {
-		json_interface["Headers"] = p.customHeaders
+	if p.usesTemplate() {
+		if p.TemplateID != 0 {
+			payload.TemplateId = p.TemplateID
+		} else {
+			payload.TemplateAlias = p.TemplateAlias
+		}
+
+		if p.TemplateModel != nil {
+			payload.TemplateModel = p.TemplateModel
+		} else {
+			payload.TemplateModel = map[string]interface{}{}
+		}
+
+		payload.InlineCss = p.InlineCSS
+	} else {
+		html, err := p.htmlBody(c)
+		if err != nil {
+			return []byte{}, err
+		}
+		payload.Subject = p.Subject
+		payload.HtmlBody = html
+		payload.TextBody = p.TextBody
 	}
 
-	return json.Marshal(json_interface)
+	return marshalJSON(payload, c.escapeHTML)
+}
+
+// Encodes v as json.Marshal does, but only
+// escaping HTML characters when escapeHTML is
+// set. Postmark accepts them unescaped
+func marshalJSON(v interface{}, escapeHTML bool) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(escapeHTML)
+	if err := encoder.Encode(v); err != nil {
+		return []byte{}, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
 }
 
 // Returns the compiled Postmark API
 // formatted JSON packet to send to
 // Postmark
 func (p *PMMail) MessageAsJSONPacket() ([]byte, error) {
-	if json_message, err := p.createJsonMessagePacket(); err != nil {
+	if json_message, err := p.createJsonMessagePacket(p.sender()); err != nil {
 		return []byte{}, err
 	} else {
 		return json_message, nil
 	}
 }
 
-// Attempts to send the email by connecting to
-// Postmark's servers and sending the
-// formatted JSON packet
-func (p *PMMail) Send() (*Reply, error) {
-
-	data, err := p.MessageAsJSONPacket()
+// Implements json.Marshaler with the packet
+// MessageAsJSONPacket returns, so messages can
+// be composed into payloads the package doesn't
+// build itself, such as a batch array from
+// json.Marshal([]*PMMail{first, second}). As
+// ever, json.Marshal escapes HTML characters,
+// which a json.Encoder can be told not to do
+func (p *PMMail) MarshalJSON() ([]byte, error) {
+	return p.MessageAsJSONPacket()
+}
 
+// Returns the same packet as MessageAsJSONPacket,
+// indented for reading and golden file tests.
+// Fields are always in the same order, with
+// headers and attachments in the order added
+func (p *PMMail) MessageAsJSONPacketIndent() ([]byte, error) {
+	packet, err := p.MessageAsJSONPacket()
 	if err != nil {
-		return nil, err
+		return []byte{}, err
 	}
 
-	badata := bytes.NewBuffer(data)
-	request, err := http.NewRequest("POST", __POSTMARK_URL__, badata)
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, packet, "", "  "); err != nil {
+		return []byte{}, err
+	}
+	return indented.Bytes(), nil
+}
 
-	request.Header.Set("Accept", "application/json")
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("X-Postmark-Server-Token", p.apiKey)
+// Attempts to send the email by connecting to
+// Postmark's servers and sending the
+// formatted JSON packet. If the request was
+// made, the Reply is returned even when the
+// send failed, with any ErrorCode and Message
+// Postmark sent
+func (p *PMMail) Send() (*Reply, error) {
+	return p.SendContext(context.Background())
+}
 
-	// Set any custom headers
-	for _, h := range p.customHeaders {
-		request.Header.Set(h.Name, h.Value)
+// Same as Send, but the request is bound to ctx
+// so it can be cancelled or given a deadline.
+// A cancelled or expired context is returned
+// wrapping ctx.Err() rather than as a network
+// error, so errors.Is can tell them apart
+func (p *PMMail) SendContext(ctx context.Context) (*Reply, error) {
+	if p.clientErr != nil {
+		return nil, p.clientErr
 	}
-
-	response, err := http.DefaultClient.Do(request)
-	if err != nil {
-		return nil, err
+	if p.client == nil {
+		return nil, fmt.Errorf("Cannot send a Message without a client, use Client.Send")
 	}
+	return p.sender().SendContext(ctx, p)
+}
+
+// Attempts to send the email using the Postmark
+// template named by TemplateID or TemplateAlias
+func (p *PMMail) SendWithTemplate() (*Reply, error) {
+	return p.SendWithTemplateContext(context.Background())
+}
 
-	switch {
-	case response.StatusCode == 401:
-		return nil, fmt.Errorf("[Postmark] HTTP error %d : Missing headers", response.StatusCode)
-	case response.StatusCode == 404:
-		return nil, fmt.Errorf("[Postmark] HTTP error %d : Page not found", response.StatusCode)
-	case response.StatusCode == 422:
-		return nil, fmt.Errorf("[Postmark] HTTP error %d : Bad JSON", response.StatusCode)
-	case response.StatusCode == 500:
-		return nil, fmt.Errorf("[Postmark] HTTP error %d : Server error", response.StatusCode)
+// Same as SendWithTemplate, but the request is
+// bound to ctx
+func (p *PMMail) SendWithTemplateContext(ctx context.Context) (*Reply, error) {
+	if p.clientErr != nil {
+		return nil, p.clientErr
+	}
+	if p.client == nil {
+		return nil, fmt.Errorf("Cannot send a Message without a client, use Client.Send")
 	}
+	return p.sender().SendWithTemplateContext(ctx, p)
+}
 
-	var body bytes.Buffer
-	_, err = io.Copy(&body, response.Body)
-	response.Body.Close()
-	if err != nil {
-		return nil, err
+// Retries sending the email as WithRetries does,
+// up to maxRetries times starting at baseDelay,
+// whether it is sent with its own Send or a
+// Client's. This only affects this message, even
+// if its client is shared. In a batch, a chunk
+// is retried as the message with the fewest
+// retries in it allows
+func (p *PMMail) SetRetryPolicy(maxRetries int, baseDelay time.Duration) error {
+	if maxRetries < 0 {
+		return fmt.Errorf("Cannot retry an e-mail %d times", maxRetries)
+	}
+	if baseDelay < 0 {
+		return fmt.Errorf("Cannot retry an e-mail with a negative delay")
 	}
 
-	reply := new(Reply)
-	json.Unmarshal([]byte(body.String()), reply)
+	p.retryPolicy = &retryPolicy{maxRetries: maxRetries, baseDelay: baseDelay}
 
-	if err != nil {
-		return nil, err
-	}
+	return nil
+}
+
+// A message's own retry policy, set by
+// SetRetryPolicy
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+}
 
-	if reply.ErrorCode != 0 {
-		return reply, fmt.Errorf("Error Code: %d", reply.ErrorCode)
+// Bounds each send of the email, including any
+// retries, to d, as WithTimeout does for every
+// call a client makes, whether it is sent with
+// its own Send or a Client's. The sooner of d,
+// the client's WithTimeout and the deadline of
+// the context passed to SendContext applies.
+// In a batch, the soonest timeout of a chunk's
+// messages bounds its request. Zero removes the
+// message's own timeout
+func (p *PMMail) SetTimeout(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("Cannot send an e-mail with a negative timeout")
 	}
+	p.timeout = d
+	return nil
+}
 
-	// Send
-	return reply, nil
+// Returns the client the message was created
+// with, pointed at Endpoint when it is set
+func (p *PMMail) sender() *Client {
+	c := p.client
+	if c == nil {
+		c = newClient("")
+	}
+	if p.Endpoint != "" {
+		c = c.withEndpoint(p.Endpoint)
+	}
+	return c
 }
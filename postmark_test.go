@@ -1,28 +1,789 @@
 package postmark
 
 import (
-    "fmt"
-    "testing"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
 )
 
 func TestPMMail(t *testing.T) {
-    p := CreatePMMail("1234567")
-    p.Sender = "Dave Martorana <themartorana@yahoo.com>"
-    p.To = "Dave Martorrrrana <dave@flyclops.com>"
-    p.Subject = "This is a test"
-    p.TextBody = "This is a test"
-    p.HTMLBody = "<strong>This is a test</strong>"
-
-    p.AddCustomHeader("X-H1", "Dave Rulez")
-    if err := p.AddAttachment("postmark.go"); err != nil {
-        t.Errorf("Error attaching file: %s\n", err)
-        t.Fail()
-    }
-
-    if packet, err := p.MessageAsJSONPacket(); err != nil {
-        t.Errorf("Trouble getting JSON packet: %s\n", err)
-        t.Fail()
-    } else {
-        fmt.Println(string(packet))
-    }
+	p := CreatePMMail("1234567")
+	p.Sender = "Dave Martorana <themartorana@yahoo.com>"
+	p.To = "Dave Martorrrrana <dave@flyclops.com>"
+	p.Subject = "This is a test"
+	p.TextBody = "This is a test"
+	p.HTMLBody = "<strong>This is a test</strong>"
+
+	p.AddCustomHeader("X-H1", "Dave Rulez")
+	if err := p.AddAttachment("postmark.go"); err != nil {
+		t.Errorf("Error attaching file: %s\n", err)
+		t.Fail()
+	}
+
+	if packet, err := p.MessageAsJSONPacket(); err != nil {
+		t.Errorf("Trouble getting JSON packet: %s\n", err)
+		t.Fail()
+	} else {
+		fmt.Println(string(packet))
+	}
+}
+
+func TestMessagePayloadFields(t *testing.T) {
+	p := testMail()
+	p.AddCC("cc@example.com")
+	p.AddBCC("bcc@example.com")
+	p.ReplyTo = "reply@example.com"
+	p.Tag = "welcome"
+	p.HTMLBody = "<p>Hello</p>"
+	p.TrackOpens = Bool(false)
+	p.TrackLinks = LinkTrackingHTMLOnly
+	p.MessageStream = MessageStreamBroadcast
+	p.AddMetadata("user", "42")
+	p.AddCustomHeader("X-H1", "Dave Rulez")
+	p.AddAttachmentFromBytes("a.txt", []byte("hello"), "text/plain")
+
+	keys := packetKeys(t, p)
+	expected := []string{"Attachments", "Bcc", "Cc", "From", "Headers", "HtmlBody", "MessageStream", "Metadata", "ReplyTo", "Subject", "Tag", "TextBody", "To", "TrackLinks", "TrackOpens"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected packet fields %v, got %v", expected, keys)
+	}
+
+	p = testMail()
+	if keys := packetKeys(t, p); !reflect.DeepEqual(keys, []string{"From", "Subject", "TextBody", "To"}) {
+		t.Errorf("Expected no optional fields, got %v", keys)
+	}
+
+	p.TemplateAlias = "welcome"
+	p.InlineCSS = Bool(true)
+	if keys := packetKeys(t, p); !reflect.DeepEqual(keys, []string{"From", "InlineCss", "TemplateAlias", "TemplateModel", "To"}) {
+		t.Errorf("Expected template fields only, got %v", keys)
+	}
+}
+
+func TestMessagePayloadTags(t *testing.T) {
+	payload := reflect.TypeOf(messagePayload{})
+	for i := 0; i < payload.NumField(); i++ {
+		field := payload.Field(i)
+		omitted := strings.HasSuffix(field.Tag.Get("json"), ",omitempty")
+		if field.Name == "From" && omitted {
+			t.Errorf("Expected From to always be sent")
+		}
+		if field.Name != "From" && !omitted {
+			t.Errorf("Expected the optional %s field to be omitted when empty", field.Name)
+		}
+	}
+}
+
+// Returns the sorted top level fields of p's
+// JSON packet
+func packetKeys(t *testing.T, p *PMMail) []string {
+	packet, err := p.MessageAsJSONPacket()
+	if err != nil {
+		t.Fatalf("Trouble getting JSON packet: %s\n", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(packet, &fields); err != nil {
+		t.Fatalf("Trouble decoding JSON packet: %s\n", err)
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestMessageAsJSONPacketIndent(t *testing.T) {
+	build := func() *PMMail {
+		p := testMail()
+		p.HTMLBody = "<p>Hi</p>"
+		for _, key := range []string{"user", "plan", "region", "cohort"} {
+			p.AddMetadata(key, key+"-value")
+		}
+		p.AddAttachmentFromBytes("b.txt", []byte("b"), "text/plain")
+		p.AddAttachmentFromBytes("a.txt", []byte("a"), "text/plain")
+		return p
+	}
+
+	first, err := build().MessageAsJSONPacketIndent()
+	if err != nil {
+		t.Fatalf("Trouble getting indented packet: %s\n", err)
+	}
+	for i := 0; i < 20; i++ {
+		if again, _ := build().MessageAsJSONPacketIndent(); string(again) != string(first) {
+			t.Fatalf("Expected the same packet every time, got\n%s\nand\n%s", first, again)
+		}
+	}
+
+	if !strings.Contains(string(first), "\n  \"From\": ") || !strings.Contains(string(first), "<p>Hi</p>") {
+		t.Errorf("Expected an indented, unescaped packet, got\n%s", first)
+	}
+	if strings.Index(string(first), "b.txt") > strings.Index(string(first), "a.txt") {
+		t.Errorf("Expected attachments in the order added, got\n%s", first)
+	}
+
+	compact, _ := build().MessageAsJSONPacket()
+	var buf bytes.Buffer
+	json.Compact(&buf, first)
+	if buf.String() != string(compact) {
+		t.Errorf("Expected the indented packet to match MessageAsJSONPacket, got\n%s\nand\n%s", buf.String(), compact)
+	}
+}
+
+func TestMessageAsJSONPacketFieldOrder(t *testing.T) {
+	p := testMail()
+	p.HTMLBody = "<p>Hi</p>"
+	p.Tag = "welcome"
+	p.AddCustomHeader("X-B", "2")
+	p.AddCustomHeader("X-A", "1")
+	p.TrackOpens = Bool(true)
+	p.MessageStream = MessageStreamBroadcast
+
+	packet, err := p.MessageAsJSONPacket()
+	if err != nil {
+		t.Fatalf("Trouble getting JSON packet: %s\n", err)
+	}
+	expected := `{"From":"Dave Martorana <themartorana@yahoo.com>","To":"Dave Martorrrrana <dave@flyclops.com>",` +
+		`"Subject":"This is a test","Tag":"welcome","HtmlBody":"<p>Hi</p>","TextBody":"This is a test",` +
+		`"Headers":[{"Name":"X-B","Value":"2"},{"Name":"X-A","Value":"1"}],"TrackOpens":true,"MessageStream":"broadcast"}`
+	if string(packet) != expected {
+		t.Errorf("Expected the packet\n%s\ngot\n%s", expected, packet)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	first, second := testMail(), testMail()
+	second.To = "someone@example.com"
+
+	data, err := json.Marshal(map[string]interface{}{"Messages": []*PMMail{first, second}})
+	if err != nil {
+		t.Fatalf("Unexpected error marshaling messages: %s", err)
+	}
+	var payload struct{ Messages []RecordedMessage }
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("Unexpected error decoding payload: %s", err)
+	}
+	if len(payload.Messages) != 2 || payload.Messages[1].To != "someone@example.com" || payload.Messages[0].Subject != first.Subject {
+		t.Errorf("Expected both messages in the payload, got %+v", payload.Messages)
+	}
+
+	packet, _ := first.MessageAsJSONPacket()
+	element, _ := json.Marshal(first)
+	var fromPacket, fromElement map[string]interface{}
+	json.Unmarshal(packet, &fromPacket)
+	json.Unmarshal(element, &fromElement)
+	if !reflect.DeepEqual(fromElement, fromPacket) {
+		t.Errorf("Expected the marshaled message to match its packet, got %s and %s", element, packet)
+	}
+
+	first.Sender = ""
+	if _, err := json.Marshal([]*PMMail{first}); err == nil || !strings.Contains(err.Error(), ".Sender field") {
+		t.Errorf("Expected the validation error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	p := &Message{}
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), ".Sender field") {
+		t.Errorf("Expected a missing sender error, got %v", err)
+	}
+
+	p = testMail()
+	if err := p.Validate(); err != nil {
+		t.Errorf("Unexpected error validating a complete message: %s", err)
+	}
+
+	p.AddCC("not an address")
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), ".CC field") {
+		t.Errorf("Expected an invalid CC address error, got %v", err)
+	}
+
+	p = testMail()
+	for i := 0; i < MaxRecipients; i++ {
+		p.AddBCC(fmt.Sprintf("r%d@example.com", i))
+	}
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), ".BCC field") {
+		t.Errorf("Expected a recipient limit error, got %v", err)
+	}
+}
+
+func TestSubjectAndHeaderChecks(t *testing.T) {
+	p := testMail()
+	p.Subject = "Hello\r\nBcc: victim@example.com"
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "line break") {
+		t.Errorf("Expected a line break error for the subject, got %v", err)
+	}
+
+	p.Subject = strings.Repeat("a", DefaultMaxSubjectLength+1)
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "999 byte subject") {
+		t.Errorf("Expected a subject length error, got %v", err)
+	}
+	p.MaxSubjectLength = 2000
+	if err := p.Validate(); err != nil {
+		t.Errorf("Unexpected error with a raised subject limit: %s", err)
+	}
+	p.MaxSubjectLength = 10
+	p.Subject = "Much too long"
+	if err := p.Validate(); err == nil {
+		t.Errorf("Expected an error over a lowered subject limit")
+	}
+
+	for _, h := range []Header{{"X-Bad\r\nBcc", "x"}, {"X-Bad: y", "x"}, {"", "x"}, {"X-Bad", "x\nBcc: victim@example.com"}} {
+		p = testMail()
+		p.AddCustomHeader(h.Name, h.Value)
+		if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "custom header") {
+			t.Errorf("Expected an error for header %q: %q, got %v", h.Name, h.Value, err)
+		}
+	}
+
+	p = testMail()
+	p.AddCustomHeader("X-Good", "fine value")
+	if err := p.Validate(); err != nil {
+		t.Errorf("Unexpected error for a valid header: %s", err)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	p := CreatePMMail("1234567")
+	if expected := "Go (Go postmark package library version 0.1)"; p.client.userAgent != expected {
+		t.Errorf("Expected user agent %q, got %q", expected, p.client.userAgent)
+	}
+}
+
+func testMail() *PMMail {
+	p := CreatePMMail("1234567")
+	p.Sender = "Dave Martorana <themartorana@yahoo.com>"
+	p.To = "Dave Martorrrrana <dave@flyclops.com>"
+	p.Subject = "This is a test"
+	p.TextBody = "This is a test"
+	return p
+}
+
+func TestSendContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := testMail().SendContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSendContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	if _, err := testMail().SendContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestTemplatePacket(t *testing.T) {
+	p := CreatePMMail("1234567")
+	p.Sender = "Dave Martorana <themartorana@yahoo.com>"
+	p.To = "Dave Martorrrrana <dave@flyclops.com>"
+	p.TemplateAlias = "welcome"
+	p.SetTemplateModel("name", "Dave")
+	p.InlineCSS = Bool(false)
+
+	packet, err := p.MessageAsJSONPacket()
+	if err != nil {
+		t.Fatalf("Trouble getting JSON packet: %s\n", err)
+	}
+
+	var message map[string]interface{}
+	if err := json.Unmarshal(packet, &message); err != nil {
+		t.Fatalf("Packet is not valid JSON: %s\n", err)
+	}
+	if message["TemplateAlias"] != "welcome" || message["InlineCss"] != false {
+		t.Errorf("Template fields missing from packet: %s", packet)
+	}
+	if model, ok := message["TemplateModel"].(map[string]interface{}); !ok || model["name"] != "Dave" {
+		t.Errorf("TemplateModel missing from packet: %s", packet)
+	}
+	for _, key := range []string{"Subject", "HtmlBody", "TextBody", "TemplateId"} {
+		if _, ok := message[key]; ok {
+			t.Errorf("Unexpected %s in template packet: %s", key, packet)
+		}
+	}
+}
+
+func TestTemplateValues(t *testing.T) {
+	p := testMail()
+	p.TemplateID = 1234
+	p.TemplateAlias = "welcome"
+	if _, err := p.MessageAsJSONPacket(); err == nil {
+		t.Errorf("Expected an error with both a template ID and alias")
+	}
+
+	if _, err := testMail().SendWithTemplate(); err == nil {
+		t.Errorf("Expected an error sending without a template")
+	}
+
+	p.TemplateAlias = ""
+	if _, err := p.Send(); err == nil {
+		t.Errorf("Expected an error sending a templated e-mail with Send")
+	}
+}
+
+func TestTrackOpens(t *testing.T) {
+	p := testMail()
+	for _, value := range []*bool{nil, Bool(true), Bool(false)} {
+		p.TrackOpens = value
+
+		packet, err := p.MessageAsJSONPacket()
+		if err != nil {
+			t.Fatalf("Trouble getting JSON packet: %s\n", err)
+		}
+
+		var message map[string]interface{}
+		if err := json.Unmarshal(packet, &message); err != nil {
+			t.Fatalf("Packet is not valid JSON: %s\n", err)
+		}
+
+		trackOpens, ok := message["TrackOpens"]
+		switch {
+		case value == nil && ok:
+			t.Errorf("Expected no TrackOpens when unset, got %v", trackOpens)
+		case value != nil && trackOpens != *value:
+			t.Errorf("Expected TrackOpens %v, got %v", *value, trackOpens)
+		}
+	}
+}
+
+func TestTrackLinks(t *testing.T) {
+	p := testMail()
+	p.TrackLinks = LinkTrackingHTMLOnly
+
+	packet, err := p.MessageAsJSONPacket()
+	if err != nil {
+		t.Fatalf("Trouble getting JSON packet: %s\n", err)
+	}
+	if !strings.Contains(string(packet), `"TrackLinks":"HtmlOnly"`) {
+		t.Errorf("Expected TrackLinks in packet: %s", packet)
+	}
+
+	p.TrackLinks = ""
+	if packet, _ := p.MessageAsJSONPacket(); strings.Contains(string(packet), "TrackLinks") {
+		t.Errorf("Expected no TrackLinks when unset: %s", packet)
+	}
+
+	p.TrackLinks = LinkTrackingNone
+	if packet, _ := p.MessageAsJSONPacket(); !strings.Contains(string(packet), `"TrackLinks":"None"`) {
+		t.Errorf("Expected TrackLinks None to override the server default: %s", packet)
+	}
+
+	p.TrackLinks = "Everything"
+	if _, err := p.MessageAsJSONPacket(); err == nil {
+		t.Errorf("Expected an error for an unknown link tracking mode")
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	p := testMail()
+	p.AddCustomHeader("Comments", "first")
+	p.AddCustomHeader("X-Campaign", "spring")
+	p.AddCustomHeader("comments", "second")
+
+	if value := p.GetHeader("COMMENTS"); value != "first" {
+		t.Errorf("Expected the first Comments header, got %q", value)
+	}
+	if value := p.GetHeader("X-Missing"); value != "" {
+		t.Errorf("Expected no value for a missing header, got %q", value)
+	}
+
+	p.SetHeader("Comments", "only")
+	expected := []Header{{"Comments", "only"}, {"X-Campaign", "spring"}}
+	if headers := p.Headers(); !reflect.DeepEqual(headers, expected) {
+		t.Errorf("Expected SetHeader to replace both Comments headers, got %v", headers)
+	}
+
+	p.SetHeader("List-Unsubscribe", "<mailto:unsubscribe@example.com>")
+	if headers := p.Headers(); len(headers) != 3 || headers[2].Name != "List-Unsubscribe" {
+		t.Errorf("Expected SetHeader to add a new header, got %v", headers)
+	}
+
+	p.Headers()[0].Value = "changed"
+	if p.GetHeader("Comments") != "only" {
+		t.Errorf("Expected Headers to return a copy")
+	}
+
+	if !p.RemoveHeader("x-campaign") || p.RemoveHeader("X-Campaign") {
+		t.Errorf("Expected RemoveHeader to report removing the header once")
+	}
+	if headers := p.Headers(); len(headers) != 2 || p.GetHeader("X-Campaign") != "" {
+		t.Errorf("Expected X-Campaign removed, got %v", headers)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	p := testMail()
+	p.AddMetadata("customer-id", "1234")
+
+	packet, err := p.MessageAsJSONPacket()
+	if err != nil {
+		t.Fatalf("Trouble getting JSON packet: %s\n", err)
+	}
+	if !strings.Contains(string(packet), `"Metadata":{"customer-id":"1234"}`) {
+		t.Errorf("Expected Metadata in packet: %s", packet)
+	}
+
+	limits := map[string]func(p *PMMail){
+		"too many entries": func(p *PMMail) {
+			for i := 0; i <= MaxMetadataEntries; i++ {
+				p.AddMetadata(fmt.Sprintf("key-%d", i), "value")
+			}
+		},
+		"long key": func(p *PMMail) {
+			p.AddMetadata(strings.Repeat("k", MaxMetadataKeyLength+1), "value")
+		},
+		"long value": func(p *PMMail) {
+			p.AddMetadata("key", strings.Repeat("v", MaxMetadataValueLength+1))
+		},
+	}
+	p = testMail()
+	p.AddMetadata("b-"+strings.Repeat("k", MaxMetadataKeyLength), "value")
+	p.AddMetadata("a-"+strings.Repeat("k", MaxMetadataKeyLength), "value")
+	for i := 0; i < 10; i++ {
+		if _, err := p.MessageAsJSONPacket(); err == nil || !strings.Contains(err.Error(), `"a-`) {
+			t.Fatalf("Expected the first key in order to be reported, got %v", err)
+		}
+	}
+
+	for name, apply := range limits {
+		p := testMail()
+		apply(p)
+		if _, err := p.MessageAsJSONPacket(); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestSendEndpoint(t *testing.T) {
+	var path, token string
+	var message map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		token = r.Header.Get("X-Postmark-Server-Token")
+		json.NewDecoder(r.Body).Decode(&message)
+		w.Write([]byte(`{"ErrorCode": 0, "Message": "OK", "MessageID": "abc-123", "To": "dave@flyclops.com"}`))
+	}))
+	defer server.Close()
+
+	p := testMail()
+	p.Endpoint = server.URL + "/"
+
+	reply, err := p.Send()
+	if err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+	if reply.MessageID != "abc-123" {
+		t.Errorf("Expected MessageID abc-123, got %q", reply.MessageID)
+	}
+	if path != "/email" || token != "1234567" {
+		t.Errorf("Expected a request to /email with the API key, got %q with %q", path, token)
+	}
+	if message["Subject"] != p.Subject {
+		t.Errorf("Expected the message packet to be posted, got %v", message)
+	}
+
+	p.TemplateAlias = "welcome"
+	if _, err := p.SendWithTemplate(); err != nil {
+		t.Fatalf("Unexpected error sending with a template: %s", err)
+	}
+	if path != "/email/withTemplate" {
+		t.Errorf("Expected a request to /email/withTemplate, got %q", path)
+	}
+}
+
+func TestMessageStream(t *testing.T) {
+	p := testMail()
+	if packet, _ := p.MessageAsJSONPacket(); strings.Contains(string(packet), "MessageStream") {
+		t.Errorf("Expected no MessageStream when unset: %s", packet)
+	}
+
+	p.MessageStream = MessageStreamBroadcast
+	if packet, _ := p.MessageAsJSONPacket(); !strings.Contains(string(packet), `"MessageStream":"broadcast"`) {
+		t.Errorf("Expected MessageStream in packet: %s", packet)
+	}
+}
+
+func TestHTMLEscaping(t *testing.T) {
+	p := testMail()
+	p.HTMLBody = "<strong>Fish & chips</strong>"
+
+	packet, err := p.MessageAsJSONPacket()
+	if err != nil {
+		t.Fatalf("Trouble getting JSON packet: %s\n", err)
+	}
+	if !strings.Contains(string(packet), `"HtmlBody":"<strong>Fish & chips</strong>"`) {
+		t.Errorf("Expected the HTML body unescaped: %s", packet)
+	}
+	if strings.HasSuffix(string(packet), "\n") {
+		t.Errorf("Expected no trailing newline: %q", packet)
+	}
+
+	p.client, _ = NewClient("1234567", WithHTMLEscaping())
+	if packet, _ := p.MessageAsJSONPacket(); !strings.Contains(string(packet), `\u003cstrong\u003eFish \u0026 chips`) {
+		t.Errorf("Expected the HTML body escaped with WithHTMLEscaping: %s", packet)
+	}
+
+	batch, err := newClient("1234567").messagesAsJSONPacket([]*PMMail{testMail(), p}, 0, false, "")
+	if err != nil {
+		t.Fatalf("Trouble getting batch packet: %s\n", err)
+	}
+	if !strings.Contains(string(batch), "<strong>") {
+		t.Errorf("Expected the batch packet unescaped: %s", batch)
+	}
+}
+
+func TestSendContextTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	p := testMail()
+	p.Endpoint = server.URL
+	if _, err := p.SendContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSetRetryPolicy(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(502)
+			return
+		}
+		if r.URL.Path == "/email/batch" {
+			w.Write([]byte(`[{"ErrorCode": 0}]`))
+			return
+		}
+		w.Write([]byte(`{"ErrorCode": 0}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	p := c.NewMail()
+	p.Sender = "dave@flyclops.com"
+	p.To = "someone@example.com"
+	p.Subject = "Hello"
+	p.TextBody = "Hello"
+
+	if err := p.SetRetryPolicy(2, time.Millisecond); err != nil {
+		t.Fatalf("Unexpected error setting retry policy: %s", err)
+	}
+	if _, err := p.Send(); err != nil {
+		t.Fatalf("Unexpected error after retries: %s", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("Expected 3 requests, got %d", n)
+	}
+	if c.retries != 0 {
+		t.Errorf("Expected the shared client to be left alone, got %d retries", c.retries)
+	}
+
+	// A message literal sent with Client.Send, and
+	// one in a batch, keep their own policies
+	atomic.StoreInt32(&requests, 0)
+	m := &Message{Sender: "dave@flyclops.com", To: "someone@example.com", Subject: "Hello", TextBody: "Hello"}
+	m.SetRetryPolicy(2, time.Millisecond)
+	if _, err := c.Send(m); err != nil {
+		t.Fatalf("Unexpected error after retries with Client.Send: %s", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("Expected 3 requests with Client.Send, got %d", n)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	if _, err := c.SendBatch([]*PMMail{m}); err != nil {
+		t.Fatalf("Unexpected error after retries with Client.SendBatch: %s", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("Expected 3 requests with Client.SendBatch, got %d", n)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	if _, err := c.Send(testMail()); err == nil {
+		t.Errorf("Expected a message without a policy not to be retried")
+	}
+
+	if err := p.SetRetryPolicy(-1, time.Millisecond); err == nil {
+		t.Errorf("Expected an error for negative retries")
+	}
+}
+
+func TestSetTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL), WithTimeout(time.Hour))
+	p := c.NewMail()
+	p.Sender = "dave@flyclops.com"
+	p.To = "someone@example.com"
+	p.Subject = "Hello"
+	p.TextBody = "Hello"
+
+	if err := p.SetTimeout(50 * time.Millisecond); err != nil {
+		t.Fatalf("Unexpected error setting timeout: %s", err)
+	}
+	start := time.Now()
+	if _, err := p.Send(); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the message's timeout to apply, took %s", elapsed)
+	}
+	if c.timeout != time.Hour {
+		t.Errorf("Expected the shared client to be left alone, got %s", c.timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	p.SetTimeout(time.Hour)
+	if _, err := p.SendContext(ctx); errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context's sooner deadline to apply, got %v", err)
+	}
+
+	short, _ := NewClient("1234567", WithTimeout(time.Millisecond))
+	if short.withTimeout(time.Hour).timeout != time.Millisecond {
+		t.Errorf("Expected the client's sooner timeout to be kept")
+	}
+
+	if err := p.SetTimeout(-time.Second); err == nil {
+		t.Errorf("Expected an error for a negative timeout")
+	}
+	p.SetTimeout(0)
+	if c.forMessages(p).timeout != time.Hour {
+		t.Errorf("Expected no timeout of the message's own after SetTimeout(0)")
+	}
+
+	// A message literal sent with Client.Send, and
+	// one in a batch, keep their own timeouts
+	m := &Message{Sender: "dave@flyclops.com", To: "someone@example.com", Subject: "Hello", TextBody: "Hello"}
+	m.SetTimeout(50 * time.Millisecond)
+	if _, err := c.Send(m); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected ErrTimeout from Client.Send, got %v", err)
+	}
+	if _, err := c.SendBatch([]*PMMail{testMail(), m}); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected ErrTimeout from Client.SendBatch, got %v", err)
+	}
+}
+
+func TestCreatePMMailOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ErrorCode": 0}`))
+	}))
+	defer server.Close()
+
+	p := CreatePMMail("1234567", WithEndpoint(server.URL), WithUserAgent("test-agent"))
+	if p.client.endpoint != server.URL || p.client.userAgent != "test-agent" {
+		t.Errorf("Expected the options to configure the client, got %+v", p.client)
+	}
+
+	p = CreatePMMail("1234567", WithEndpoint("not a url"))
+	p.Sender = "dave@flyclops.com"
+	p.To = "someone@example.com"
+	p.Subject = "Hello"
+	p.TextBody = "Hello"
+	if _, err := p.Send(); err == nil || !strings.Contains(err.Error(), "not a url") {
+		t.Errorf("Expected the invalid option to be reported by Send, got %v", err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	p := testMail()
+	p.AddCustomHeader("X-H1", "Dave Rulez")
+	p.AddAttachmentFromBytes("notes.txt", []byte("hello"), "text/plain")
+	p.AddRecipient("other@example.com")
+	p.AddMetadata("customer-id", "1234")
+	p.TrackOpens = Bool(true)
+
+	clone := p.Clone()
+	clone.To = "someone@example.com"
+	clone.AddCustomHeader("X-H2", "Another")
+	clone.customHeaders[0].Value = "Changed"
+	clone.attachments[0].Name = "changed.txt"
+	clone.recipients[0] = "changed@example.com"
+	clone.AddMetadata("customer-id", "5678")
+	*clone.TrackOpens = false
+
+	if p.To != "Dave Martorrrrana <dave@flyclops.com>" || len(p.customHeaders) != 1 || p.customHeaders[0].Value != "Dave Rulez" {
+		t.Errorf("Expected the original's fields and headers to be untouched, got %+v", p)
+	}
+	if p.attachments[0].Name != "notes.txt" || p.recipients[0] != "other@example.com" {
+		t.Errorf("Expected the original's attachments and recipients to be untouched")
+	}
+	if p.Metadata["customer-id"] != "1234" || !*p.TrackOpens {
+		t.Errorf("Expected the original's metadata and tracking to be untouched")
+	}
+	if clone.client != p.client {
+		t.Errorf("Expected the clone to share the client")
+	}
+}
+
+func TestReset(t *testing.T) {
+	p := testMail()
+	p.Endpoint = "http://localhost"
+	p.MaxAttachmentBytes = 1024
+	p.MaxMessageBytes = 2048
+	p.MaxDownloadBytes = 512
+	p.MaxSubjectLength = 100
+	p.SetTimeout(time.Second)
+	p.SetRetryPolicy(3, time.Second)
+	p.AddCustomHeader("X-H1", "Dave Rulez")
+	p.AddAttachmentFromBytes("notes.txt", []byte("hello"), "text/plain")
+	p.AddRecipient("other@example.com")
+	p.AddMetadata("customer-id", "1234")
+	p.TemplateAlias = "welcome"
+	client := p.client
+
+	p.Reset()
+	if p.client != client || p.Endpoint != "http://localhost" || p.MaxAttachmentBytes != 1024 || p.MaxMessageBytes != 2048 || p.MaxDownloadBytes != 512 || p.MaxSubjectLength != 100 || p.timeout != time.Second || p.retryPolicy == nil {
+		t.Errorf("Expected the client, Endpoint and limits to survive, got %+v", p)
+	}
+	if p.Sender != "" || p.To != "" || p.Subject != "" || p.TextBody != "" || p.TemplateAlias != "" || p.Metadata != nil {
+		t.Errorf("Expected the content to be cleared, got %+v", p)
+	}
+	if len(p.customHeaders) != 0 || len(p.attachments) != 0 || len(p.recipients) != 0 {
+		t.Errorf("Expected headers, attachments and recipients to be removed")
+	}
+}
+
+func TestReplySubmittedTime(t *testing.T) {
+	reply := Reply{SubmittedAt: "2024-01-02T15:04:05.1234567-05:00"}
+	submitted, err := reply.SubmittedTime()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing SubmittedAt: %s", err)
+	}
+	if expected := time.Date(2024, 1, 2, 20, 4, 5, 123456700, time.UTC); !submitted.Equal(expected) {
+		t.Errorf("Expected %s, got %s", expected, submitted)
+	}
+
+	for _, value := range []string{"", "yesterday"} {
+		reply.SubmittedAt = value
+		if _, err := reply.SubmittedTime(); err == nil {
+			t.Errorf("Expected an error parsing %q", value)
+		}
+	}
 }
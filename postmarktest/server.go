@@ -0,0 +1,209 @@
+// Package postmarktest provides a fake Postmark API
+// server for integration tests
+package postmarktest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	postmark "github.com/yourheropaul/Gostmark"
+)
+
+// The most messages the batch endpoints accept,
+// as with the real API
+const batchLimit int = 500
+
+// A message accepted by a Server, with the ID
+// it was assigned
+type Message struct {
+	postmark.RecordedMessage
+	MessageID   string
+	SubmittedAt time.Time
+}
+
+// A fake Postmark API, answering the single,
+// template and batch send endpoints the way
+// Postmark does, including gzip compressed
+// requests. Point a client at it with
+// postmark.WithEndpoint(s.URL). It is safe for
+// concurrent use
+type Server struct {
+	*httptest.Server
+
+	// The server token requests must carry
+	Token string
+
+	mu       sync.Mutex
+	messages []Message
+	nextID   int
+}
+
+// Create and start a new Server accepting the
+// given server token, and return a pointer to
+// it. The caller should Close it when done
+func NewServer(token string) *Server {
+	s := &Server{Token: token}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Returns the messages accepted so far, in the
+// order they were sent
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Forgets every message accepted so far
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+type reply struct {
+	ErrorCode   int
+	Message     string
+	MessageID   string `json:",omitempty"`
+	SubmittedAt string `json:",omitempty"`
+	To          string `json:",omitempty"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, reply{Message: "Method not allowed"})
+		return
+	}
+	if r.Header.Get("X-Postmark-Server-Token") != s.Token {
+		writeJSON(w, http.StatusUnauthorized, reply{
+			ErrorCode: int(postmark.ErrCodeBadAPIToken),
+			Message:   "Request does not contain a valid Server token.",
+		})
+		return
+	}
+
+	var body []byte
+	var err error
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(r.Body); err == nil {
+			body, err = ioutil.ReadAll(gz)
+		}
+	} else {
+		body, err = ioutil.ReadAll(r.Body)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, reply{Message: err.Error()})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/email", "/email/withTemplate":
+		var m postmark.RecordedMessage
+		if err := json.Unmarshal(body, &m); err != nil {
+			writeInvalidJSON(w, err)
+			return
+		}
+		rep := s.accept(m, r.URL.Path == "/email/withTemplate")
+		if rep.ErrorCode != 0 {
+			writeJSON(w, http.StatusUnprocessableEntity, rep)
+			return
+		}
+		writeJSON(w, http.StatusOK, rep)
+	case "/email/batch", "/email/batchWithTemplates":
+		templates := r.URL.Path == "/email/batchWithTemplates"
+		var messages []postmark.RecordedMessage
+		if templates {
+			var batch struct{ Messages []postmark.RecordedMessage }
+			err = json.Unmarshal(body, &batch)
+			messages = batch.Messages
+		} else {
+			err = json.Unmarshal(body, &messages)
+		}
+		if err != nil {
+			writeInvalidJSON(w, err)
+			return
+		}
+		if len(messages) > batchLimit {
+			writeJSON(w, http.StatusUnprocessableEntity, reply{
+				ErrorCode: int(postmark.ErrCodeTooManyBatchMessages),
+				Message:   fmt.Sprintf("Batch contains %d messages, the limit is %d.", len(messages), batchLimit),
+			})
+			return
+		}
+		replies := make([]reply, len(messages))
+		for i, m := range messages {
+			replies[i] = s.accept(m, templates)
+		}
+		writeJSON(w, http.StatusOK, replies)
+	default:
+		writeJSON(w, http.StatusNotFound, reply{Message: "Page not found"})
+	}
+}
+
+// Validates a message as Postmark does, storing
+// it if it is accepted, and returns the reply
+func (s *Server) accept(m postmark.RecordedMessage, template bool) reply {
+	if msg := validate(m, template); msg != "" {
+		return reply{ErrorCode: int(postmark.ErrCodeInvalidEmailRequest), Message: msg}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	accepted := Message{
+		RecordedMessage: m,
+		MessageID:       fmt.Sprintf("00000000-0000-0000-0000-%012d", s.nextID),
+		SubmittedAt:     time.Now(),
+	}
+	s.messages = append(s.messages, accepted)
+
+	return reply{
+		Message:     "OK",
+		MessageID:   accepted.MessageID,
+		SubmittedAt: accepted.SubmittedAt.Format(time.RFC3339),
+		To:          m.To,
+	}
+}
+
+// Returns Postmark's explanation of why a
+// message is invalid, or "" if it is valid
+func validate(m postmark.RecordedMessage, template bool) string {
+	if m.From == "" {
+		return "Invalid 'From' address: ''."
+	}
+	if m.To == "" && m.Cc == "" && m.Bcc == "" {
+		return "Zero recipients specified"
+	}
+	if template {
+		if m.TemplateId == 0 && m.TemplateAlias == "" {
+			return "Either TemplateId or TemplateAlias must be specified."
+		}
+		return ""
+	}
+	if m.HtmlBody == "" && m.TextBody == "" {
+		return "Provide either email TextBody or HtmlBody or both."
+	}
+	return ""
+}
+
+func writeInvalidJSON(w http.ResponseWriter, err error) {
+	writeJSON(w, http.StatusUnprocessableEntity, reply{
+		ErrorCode: int(postmark.ErrCodeInvalidJSON),
+		Message:   "Received invalid JSON input: " + err.Error(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
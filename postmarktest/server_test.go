@@ -0,0 +1,76 @@
+package postmarktest
+
+import (
+	"testing"
+
+	postmark "github.com/yourheropaul/Gostmark"
+)
+
+func testMail(c *postmark.Client) *postmark.PMMail {
+	m := c.NewMail()
+	m.Sender = "Dave Martorana <themartorana@yahoo.com>"
+	m.To = "dave@flyclops.com"
+	m.Subject = "This is a test"
+	m.TextBody = "This is a test"
+	return m
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer("1234567")
+	defer s.Close()
+
+	c, _ := postmark.NewClient("1234567", postmark.WithEndpoint(s.URL))
+	reply, err := testMail(c).Send()
+	if err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+
+	messages := s.Messages()
+	if len(messages) != 1 || messages[0].MessageID != reply.MessageID || messages[0].Subject != "This is a test" {
+		t.Errorf("Expected the message to be stored with its MessageID, got %+v", messages)
+	}
+
+	m := testMail(c)
+	m.TemplateAlias = "welcome"
+	m.SetTemplateModel("name", "Dave")
+	if _, err := m.SendWithTemplate(); err != nil {
+		t.Fatalf("Unexpected error sending with a template: %s", err)
+	}
+	if messages := s.Messages(); len(messages) != 2 || messages[1].TemplateModel["name"] != "Dave" {
+		t.Errorf("Expected the template model to be stored, got %+v", messages)
+	}
+
+	replies, err := c.SendBatch([]*postmark.PMMail{testMail(c), testMail(c)})
+	if err != nil || len(replies) != 2 || replies[0].MessageID == replies[1].MessageID {
+		t.Errorf("Expected distinct MessageIDs for the batch, got %+v and %v", replies, err)
+	}
+
+	s.Reset()
+	if n := len(s.Messages()); n != 0 {
+		t.Errorf("Expected Reset to forget messages, got %d", n)
+	}
+}
+
+func TestServerBadToken(t *testing.T) {
+	s := NewServer("1234567")
+	defer s.Close()
+
+	c, _ := postmark.NewClient("wrong", postmark.WithEndpoint(s.URL))
+	if _, err := testMail(c).Send(); !postmark.IsErrorCode(err, postmark.ErrCodeBadAPIToken) {
+		t.Errorf("Expected a bad API token error, got %v", err)
+	}
+}
+
+func TestServerValidation(t *testing.T) {
+	m := postmark.RecordedMessage{From: "dave@flyclops.com", To: "someone@example.com"}
+	if validate(m, false) == "" {
+		t.Errorf("Expected a message without a body to be rejected")
+	}
+	if validate(m, true) == "" {
+		t.Errorf("Expected a templated message without a template to be rejected")
+	}
+	m.TextBody = "Hello"
+	if msg := validate(m, false); msg != "" {
+		t.Errorf("Expected a valid message to be accepted, got %q", msg)
+	}
+}
@@ -0,0 +1,100 @@
+package postmark
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A token bucket limiting how often requests are
+// made. Pass one to WithRateLimiter to share a
+// limit between several clients. It is safe for
+// concurrent use
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// Create a new RateLimiter allowing a sustained
+// requestsPerSecond, with bursts of up to burst
+// requests, and return a pointer to it
+func NewRateLimiter(requestsPerSecond float64, burst int) (*RateLimiter, error) {
+	if requestsPerSecond <= 0 {
+		return nil, fmt.Errorf("Cannot create a rate limiter allowing %v requests per second", requestsPerSecond)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("Cannot create a rate limiter with a burst of %d", burst)
+	}
+
+	return &RateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}, nil
+}
+
+// Blocks until a request may be made, or until
+// ctx is done. No token is used up when the
+// context ends first, or when its deadline is
+// too soon to wait for one
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	wait, err := l.reserve(ctx)
+	if err != nil || wait == 0 {
+		return err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.refund()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Takes a token, returning how long the caller
+// must wait before it becomes available
+func (l *RateLimiter) reserve(ctx context.Context) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	var wait time.Duration
+	if l.tokens < 1 {
+		wait = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		if deadline, ok := ctx.Deadline(); ok && deadline.Sub(now) < wait {
+			return 0, context.DeadlineExceeded
+		}
+	}
+
+	l.tokens--
+	return wait, nil
+}
+
+func (l *RateLimiter) refund() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
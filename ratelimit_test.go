@@ -0,0 +1,87 @@
+package postmark
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l, err := NewRateLimiter(20, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error creating limiter: %s", err)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.Wait(context.Background()); err != nil {
+				t.Errorf("Unexpected error waiting: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 2 requests from the burst, then 4 more at 20 per second
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected 6 requests to take about 200ms, took %s", elapsed)
+	}
+}
+
+func TestRateLimiterContext(t *testing.T) {
+	l, _ := NewRateLimiter(1, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Unexpected error waiting: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	for _, args := range [][2]float64{{0, 1}, {1, 0}} {
+		if _, err := NewRateLimiter(args[0], int(args[1])); err == nil {
+			t.Errorf("Expected an error creating a limiter with %v", args)
+		}
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ErrorCode": 0}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient("1234567", WithEndpoint(server.URL), WithRateLimit(1, 1))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %s", err)
+	}
+	shared, err := NewClient("7654321", WithEndpoint(server.URL), WithRateLimiter(c.RateLimiter()))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %s", err)
+	}
+
+	if _, err := c.Send(testMail()); err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := shared.SendContext(ctx, testMail()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the shared limiter to hold the send past its deadline, got %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package postmark
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// The maximum number of recipients Postmark
+// accepts for a single message
+const MaxRecipients int = 50
+
+// A mailbox with an optional display name, such
+// as Address{"Dave Martorana", "dave@example.com"}
+type Address struct {
+	Name  string
+	Email string
+}
+
+// Parses a single RFC 5322 mailbox, such as
+// "Dave Martorana <dave@example.com>", decoding
+// any encoded display name
+func ParseAddress(s string) (Address, error) {
+	a, err := mail.ParseAddress(s)
+	if err != nil {
+		return Address{}, fmt.Errorf("Cannot parse address %q: %s", s, err)
+	}
+	return Address{Name: a.Name, Email: a.Address}, nil
+}
+
+// Returns the address as an RFC 5322 mailbox,
+// quoting or encoding the display name when it
+// has commas or other special or non-ASCII
+// characters
+func (a Address) String() string {
+	return (&mail.Address{Name: a.Name, Address: a.Email}).String()
+}
+
+// Add recipients to the email message, such as
+// a slice of addresses with AddRecipient(to...).
+// Once any are added they take precedence over
+// the To field, which is then ignored. They are
+// joined with display names left intact, so
+// each address must be a single RFC 5322 mailbox
+func (p *PMMail) AddRecipient(addrs ...string) {
+	p.recipients = append(p.recipients, addrs...)
+}
+
+// Set the address the message is from, with
+// its display name quoted or encoded as RFC 5322
+// requires, such as when it contains a comma
+func (p *PMMail) SetSender(a mail.Address) {
+	p.Sender = a.String()
+}
+
+// Set the address the message is from from its
+// display name and e-mail address, quoted as
+// SetSender does. An empty name sets the bare
+// address
+func (p *PMMail) SetFrom(name, address string) {
+	if name == "" {
+		p.Sender = address
+		return
+	}
+	p.SetSender(mail.Address{Name: name, Address: address})
+}
+
+// Add a recipient as AddRecipient does, with
+// its display name quoted or encoded as RFC 5322
+// requires
+func (p *PMMail) AddRecipientAddress(a mail.Address) {
+	p.AddRecipient(a.String())
+}
+
+// Add a recipient from its display name and
+// e-mail address, formatted as Address does
+func (p *PMMail) AddToAddress(name, email string) {
+	p.AddRecipient(Address{Name: name, Email: email}.String())
+}
+
+// Add CC recipients to the email message. Once
+// any are added they take precedence over the
+// CC field, as with AddRecipient
+func (p *PMMail) AddCC(addrs ...string) {
+	p.cc = append(p.cc, addrs...)
+}
+
+// Add BCC recipients to the email message. Once
+// any are added they take precedence over the
+// BCC field, as with AddRecipient
+func (p *PMMail) AddBCC(addrs ...string) {
+	p.bcc = append(p.bcc, addrs...)
+}
+
+// Returns the recipients added by AddRecipient,
+// or the To field if there are none
+func (p *PMMail) toList() string {
+	lists, _ := p.recipientLists(p.DedupeRecipients)
+	return strings.Join(lists[0], ", ")
+}
+
+// Returns the recipients added by AddCC, or
+// the CC field if there are none
+func (p *PMMail) ccList() string {
+	lists, _ := p.recipientLists(p.DedupeRecipients)
+	return strings.Join(lists[1], ", ")
+}
+
+// Returns the recipients added by AddBCC, or
+// the BCC field if there are none
+func (p *PMMail) bccList() string {
+	lists, _ := p.recipientLists(p.DedupeRecipients)
+	return strings.Join(lists[2], ", ")
+}
+
+// Returns the recipients DedupeRecipients
+// removes, or would remove if it were set, in
+// the order they were found, for logging
+func (p *PMMail) DuplicateRecipients() []string {
+	_, removed := p.recipientLists(true)
+	return removed
+}
+
+// Returns the To, CC and BCC lists, each taken
+// from its added recipients when there are any
+// and its field otherwise. With dedupe, an
+// address already in an earlier list, or
+// earlier in the same list, is removed and
+// returned in removed. Addresses are compared
+// without their display names or case
+func (p *PMMail) recipientLists(dedupe bool) (lists [3][]string, removed []string) {
+	lists = [3][]string{
+		recipientList(p.To, p.recipients),
+		recipientList(p.CC, p.cc),
+		recipientList(p.BCC, p.bcc),
+	}
+	if !dedupe {
+		return lists, nil
+	}
+
+	seen := make(map[string]bool)
+	for i, list := range lists {
+		kept := list[:0]
+		for _, addr := range list {
+			key := addr
+			if parsed, err := mail.ParseAddress(addr); err == nil {
+				key = parsed.Address
+			}
+			key = strings.ToLower(key)
+			if seen[key] {
+				removed = append(removed, addr)
+				continue
+			}
+			seen[key] = true
+			kept = append(kept, addr)
+		}
+		lists[i] = kept
+	}
+	return lists, removed
+}
+
+// Checks that To, Cc and Bcc together stay
+// within MaxRecipients, naming the field that
+// took the total over the limit
+func (p *PMMail) checkRecipients() error {
+	buckets := []struct {
+		field string
+		list  string
+	}{
+		{"To", p.toList()},
+		{"CC", p.ccList()},
+		{"BCC", p.bccList()},
+	}
+
+	total := 0
+	for _, b := range buckets {
+		n := len(splitAddressList(b.list))
+		total += n
+		if total > MaxRecipients {
+			return fmt.Errorf("Cannot send e-mail to %d recipients, the limit is %d across To, CC and BCC (.%s field has %d)", total, MaxRecipients, b.field, n)
+		}
+	}
+
+	return nil
+}
+
+// Checks that every address the message is
+// from, to or replied to parses, naming the
+// field with the first one that doesn't
+func (p *PMMail) checkAddresses() error {
+	if _, err := mail.ParseAddress(p.Sender); err != nil {
+		return fmt.Errorf("Cannot send e-mail from invalid address %q (.Sender field): %s", p.Sender, err)
+	}
+
+	fields := []struct {
+		field string
+		list  string
+	}{
+		{"ReplyTo", p.ReplyTo},
+		{"To", p.toList()},
+		{"CC", p.ccList()},
+		{"BCC", p.bccList()},
+	}
+	for _, f := range fields {
+		for _, addr := range splitAddressList(f.list) {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				return fmt.Errorf("Cannot send e-mail with invalid address %q (.%s field): %s", addr, f.field, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the added addresses, or if none were
+// added the comma separated address list field
+func recipientList(field string, added []string) []string {
+	if len(added) == 0 {
+		return splitAddressList(field)
+	}
+
+	var addrs []string
+	for _, addr := range added {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return splitAddressList(strings.Join(addrs, ", "))
+}
+
+// Splits a comma separated address list,
+// ignoring commas inside quoted display
+// names and angle brackets
+func splitAddressList(list string) []string {
+	var addrs []string
+	var quoted, escaped bool
+	var angled, start int
+
+	add := func(addr string) {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	for i, r := range list {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '<':
+			angled++
+		case r == '>' && angled > 0:
+			angled--
+		case r == ',' && angled == 0:
+			add(list[start:i])
+			start = i + 1
+		}
+	}
+	add(list[start:])
+
+	return addrs
+}
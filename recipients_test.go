@@ -0,0 +1,284 @@
+package postmark
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitAddressList(t *testing.T) {
+	tests := map[string][]string{
+		"":                              nil,
+		"dave@flyclops.com":             {"dave@flyclops.com"},
+		"a@example.com, b@example.com,": {"a@example.com", "b@example.com"},
+		`"Martorana, Dave" <dave@flyclops.com>, b@example.com`: {`"Martorana, Dave" <dave@flyclops.com>`, "b@example.com"},
+		`"Dave \"The, Man\"" <dave@flyclops.com>`:              {`"Dave \"The, Man\"" <dave@flyclops.com>`},
+	}
+
+	for list, expected := range tests {
+		if addrs := splitAddressList(list); !reflect.DeepEqual(addrs, expected) {
+			t.Errorf("splitAddressList(%q): expected %q, got %q", list, expected, addrs)
+		}
+	}
+}
+
+func TestAddRecipient(t *testing.T) {
+	p := testMail()
+	p.AddRecipient("a@example.com")
+	p.AddRecipient("B <b@example.com>")
+
+	// Added recipients take precedence over To
+	expected := "a@example.com, B <b@example.com>"
+	if to := p.toList(); to != expected {
+		t.Errorf("Expected To %q, got %q", expected, to)
+	}
+
+	p.To = ""
+	if to := p.toList(); to != expected {
+		t.Errorf("Expected only added recipients, got %q", to)
+	}
+	if _, err := p.MessageAsJSONPacket(); err != nil {
+		t.Errorf("Unexpected error with only added recipients: %s", err)
+	}
+}
+
+func TestAddressSetters(t *testing.T) {
+	p := testMail()
+	p.To = ""
+	p.SetSender(mail.Address{Name: "Martorana, Dave", Address: "themartorana@yahoo.com"})
+	p.AddRecipientAddress(mail.Address{Name: "Doe, Jane", Address: "jane@example.com"})
+	p.AddRecipientAddress(mail.Address{Address: "john@example.com"})
+
+	if expected := `"Martorana, Dave" <themartorana@yahoo.com>`; p.Sender != expected {
+		t.Errorf("Expected Sender %q, got %q", expected, p.Sender)
+	}
+	if to := splitAddressList(p.toList()); len(to) != 2 || to[0] != `"Doe, Jane" <jane@example.com>` || to[1] != "<john@example.com>" {
+		t.Errorf("Expected two quoted recipients, got %q", to)
+	}
+	if _, err := p.MessageAsJSONPacket(); err != nil {
+		t.Errorf("Unexpected error with typed addresses: %s", err)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := map[Address]string{
+		{Email: "dave@example.com"}:                          "<dave@example.com>",
+		{Name: "Dave Martorana", Email: "dave@example.com"}:  `"Dave Martorana" <dave@example.com>`,
+		{Name: "Martorana, Dave", Email: "dave@example.com"}: `"Martorana, Dave" <dave@example.com>`,
+		{Name: "Dávid", Email: "dave@example.com"}:           "=?utf-8?q?D=C3=A1vid?= <dave@example.com>",
+	}
+	for a, expected := range tests {
+		if s := a.String(); s != expected {
+			t.Errorf("Expected %+v as %q, got %q", a, expected, s)
+		}
+		if parsed, err := ParseAddress(a.String()); err != nil || parsed != a {
+			t.Errorf("Expected %q to parse back to %+v, got %+v and %v", a.String(), a, parsed, err)
+		}
+	}
+
+	if _, err := ParseAddress("Dave <dave"); err == nil {
+		t.Errorf("Expected an error parsing an unterminated address")
+	}
+
+	p := testMail()
+	p.To = ""
+	p.AddToAddress("Doe, Jane", "jane@example.com")
+	if to := p.toList(); to != `"Doe, Jane" <jane@example.com>` {
+		t.Errorf("Expected a quoted recipient, got %q", to)
+	}
+}
+
+func TestSetFrom(t *testing.T) {
+	p := testMail()
+	p.SetFrom("Martorana, Dave", "themartorana@yahoo.com")
+	if expected := `"Martorana, Dave" <themartorana@yahoo.com>`; p.Sender != expected {
+		t.Errorf("Expected Sender %q, got %q", expected, p.Sender)
+	}
+
+	p.SetFrom("", "themartorana@yahoo.com")
+	if p.Sender != "themartorana@yahoo.com" {
+		t.Errorf("Expected the bare address, got %q", p.Sender)
+	}
+	if err := p.Validate(); err != nil {
+		t.Errorf("Unexpected error with a bare sender: %s", err)
+	}
+}
+
+func TestAddRecipientSlices(t *testing.T) {
+	p := testMail()
+	p.To = ""
+	p.AddRecipient(`"Doe, Jane" <jane@example.com>`, "john@example.com")
+	p.AddCC([]string{"cc1@example.com", "cc2@example.com"}...)
+	p.AddBCC()
+
+	if to := splitAddressList(p.toList()); len(to) != 2 || to[0] != `"Doe, Jane" <jane@example.com>` {
+		t.Errorf("Expected two recipients with the display name kept whole, got %q", to)
+	}
+	if cc := p.ccList(); cc != "cc1@example.com, cc2@example.com" {
+		t.Errorf("Expected both CC recipients, got %q", cc)
+	}
+	if bcc := p.bccList(); bcc != "" {
+		t.Errorf("Expected no BCC recipients, got %q", bcc)
+	}
+
+	many := make([]string, MaxRecipients-3)
+	for i := range many {
+		many[i] = fmt.Sprintf("r%d@example.com", i)
+	}
+	p.AddBCC(many...)
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "51 recipients") {
+		t.Errorf("Expected an error naming the 51 recipients, got %v", err)
+	}
+}
+
+func TestRecipientLimit(t *testing.T) {
+	p := testMail()
+	for i := 0; i < MaxRecipients; i++ {
+		p.AddRecipient("a@example.com")
+	}
+	if _, err := p.MessageAsJSONPacket(); err != nil {
+		t.Errorf("Unexpected error with %d recipients: %s", MaxRecipients, err)
+	}
+
+	p.AddRecipient("a@example.com")
+	if _, err := p.MessageAsJSONPacket(); err == nil || !strings.Contains(err.Error(), "51") {
+		t.Errorf("Expected an error with %d recipients, got %v", MaxRecipients+1, err)
+	}
+}
+
+func TestAddCCAndBCC(t *testing.T) {
+	p := testMail()
+	p.CC = "c@example.com"
+	p.AddCC("d@example.com")
+	p.AddBCC("e@example.com")
+
+	packet, err := p.MessageAsJSONPacket()
+	if err != nil {
+		t.Fatalf("Trouble getting JSON packet: %s\n", err)
+	}
+	if !strings.Contains(string(packet), `"Cc":"d@example.com"`) {
+		t.Errorf("Expected the added Cc to take precedence in packet: %s", packet)
+	}
+	if !strings.Contains(string(packet), `"To":"Dave Martorrrrana <dave@flyclops.com>"`) {
+		t.Errorf("Expected the To field without added recipients in packet: %s", packet)
+	}
+	if !strings.Contains(string(packet), `"Bcc":"e@example.com"`) {
+		t.Errorf("Expected Bcc in packet: %s", packet)
+	}
+}
+
+func TestCombinedRecipientLimit(t *testing.T) {
+	p := testMail()
+	for i := 0; i < 30; i++ {
+		p.AddCC("c@example.com")
+	}
+	for i := 0; i < 20; i++ {
+		p.AddBCC("b@example.com")
+	}
+
+	_, err := p.MessageAsJSONPacket()
+	if err == nil {
+		t.Fatalf("Expected an error with %d recipients", MaxRecipients+1)
+	}
+	if !strings.Contains(err.Error(), ".BCC field") {
+		t.Errorf("Expected the error to name the BCC field, got %s", err)
+	}
+}
+
+func TestCheckAddresses(t *testing.T) {
+	if _, err := testMail().MessageAsJSONPacket(); err != nil {
+		t.Errorf("Expected display-name addresses to be accepted, got %s", err)
+	}
+
+	invalid := map[string]func(p *PMMail){
+		"Sender":  func(p *PMMail) { p.Sender = "dave@@flyclops.com" },
+		"ReplyTo": func(p *PMMail) { p.ReplyTo = "not an address" },
+		"To":      func(p *PMMail) { p.AddRecipient("someone@") },
+		"CC":      func(p *PMMail) { p.CC = "a@example.com, b@@example.com" },
+		"BCC":     func(p *PMMail) { p.AddBCC("<unterminated@example.com") },
+	}
+	for field, apply := range invalid {
+		p := testMail()
+		apply(p)
+		_, err := p.MessageAsJSONPacket()
+		if err == nil || !strings.Contains(err.Error(), "(."+field+" field)") {
+			t.Errorf("Expected an error naming the %s field, got %v", field, err)
+		}
+	}
+}
+
+func TestWithoutAddressValidation(t *testing.T) {
+	p := CreatePMMail("1234567", WithoutAddressValidation())
+	p.Sender = "dave@flyclops.com"
+	p.To = "someone@example.com"
+	p.AddCC("no-at-sign")
+	p.Subject = "Hello"
+	p.TextBody = "Hello"
+	if err := p.Validate(); err != nil {
+		t.Errorf("Expected addresses to go unchecked, got %s", err)
+	}
+	if _, err := p.MessageAsJSONPacket(); err != nil {
+		t.Errorf("Expected a packet without address validation, got %s", err)
+	}
+
+	p.client = newClient("1234567")
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), `"no-at-sign" (.CC field)`) {
+		t.Errorf("Expected the invalid CC address by default, got %v", err)
+	}
+}
+
+func TestDedupeRecipients(t *testing.T) {
+	p := testMail()
+	p.To = ""
+	p.AddRecipient("Dave Martorrrrana <dave@flyclops.com>", "DAVE@flyclops.com")
+	p.AddCC("Dave <dave@FLYCLOPS.com>", "cc@example.com")
+	p.AddBCC("cc@example.com", "bcc@example.com", "BCC@example.com")
+
+	if removed := p.DuplicateRecipients(); len(removed) != 4 {
+		t.Errorf("Expected 4 duplicates reported without DedupeRecipients, got %q", removed)
+	}
+	if to := p.toList(); len(splitAddressList(to)) != 2 {
+		t.Errorf("Expected duplicates sent without DedupeRecipients, got %q", to)
+	}
+
+	p.DedupeRecipients = true
+	if to := p.toList(); to != "Dave Martorrrrana <dave@flyclops.com>" {
+		t.Errorf("Expected the first To address only, got %q", to)
+	}
+	if cc := p.ccList(); cc != "cc@example.com" {
+		t.Errorf("Expected CC to lose to To, got %q", cc)
+	}
+	if bcc := p.bccList(); bcc != "bcc@example.com" {
+		t.Errorf("Expected BCC to lose to CC, got %q", bcc)
+	}
+
+	expected := []string{"DAVE@flyclops.com", "Dave <dave@FLYCLOPS.com>", "cc@example.com", "BCC@example.com"}
+	if removed := p.DuplicateRecipients(); !reflect.DeepEqual(removed, expected) {
+		t.Errorf("Expected removed %q, got %q", expected, removed)
+	}
+}
+
+func TestBCCOnly(t *testing.T) {
+	p := testMail()
+	p.To = ""
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "without a recipient (.To, .CC or .BCC field)") {
+		t.Errorf("Expected a clear error for no recipients, got %v", err)
+	}
+
+	p.AddBCC("announcements@example.com")
+	recorder := NewRecorder()
+	c, _ := NewClient("1234567", WithHTTPClient(recorder.Client()))
+	if _, err := c.Send(p); err != nil {
+		t.Fatalf("Unexpected error sending a BCC only message: %s", err)
+	}
+
+	packet, _ := p.MessageAsJSONPacket()
+	if strings.Contains(string(packet), `"To"`) {
+		t.Errorf("Expected no To field in the packet: %s", packet)
+	}
+	if sent := recorder.Sent(); len(sent) != 1 || sent[0].To != "" || sent[0].Bcc != "announcements@example.com" {
+		t.Errorf("Expected one message to the BCC recipient, got %+v", sent)
+	}
+}
@@ -0,0 +1,204 @@
+package postmark
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// A message captured by a Recorder, decoded
+// from the JSON the client sent
+type RecordedMessage struct {
+	From          string
+	To            string
+	Cc            string
+	Bcc           string
+	ReplyTo       string
+	Subject       string
+	HtmlBody      string
+	TextBody      string
+	Tag           string
+	MessageStream string
+	Metadata      map[string]string
+	TemplateId    int
+	TemplateAlias string
+	TemplateModel map[string]interface{}
+	Headers       []RecordedHeader
+	Attachments   []RecordedAttachment
+}
+
+type RecordedHeader struct {
+	Name  string
+	Value string
+}
+
+// An attachment captured by a Recorder, with
+// its content decoded from base64
+type RecordedAttachment struct {
+	Name        string
+	ContentType string
+	ContentID   string
+	Content     []byte
+}
+
+// An http.RoundTripper that records the messages
+// a Client sends instead of sending them, for
+// unit testing code that sends e-mail. Create
+// one with NewRecorder and pass its Client to
+// WithHTTPClient. It is safe for concurrent use
+type Recorder struct {
+	mu      sync.Mutex
+	sent    []RecordedMessage
+	replies []Reply
+	err     error
+	nextID  int
+}
+
+// Create a new Recorder that accepts every
+// message, and return a pointer to it
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Returns an HTTP client that sends through
+// the recorder
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Queues replies for the next messages sent,
+// one per message. A reply with a non-zero
+// ErrorCode rejects its message as Postmark
+// would. Once the queue is empty, messages are
+// accepted with a generated MessageID
+func (r *Recorder) QueueReplies(replies ...Reply) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replies = append(r.replies, replies...)
+}
+
+// Makes every request fail with err, as a
+// network error would, until it is called
+// again with nil. Failed requests record
+// nothing
+func (r *Recorder) FailWith(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// Returns the messages sent so far, in the
+// order they were sent
+func (r *Recorder) Sent() []RecordedMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedMessage(nil), r.sent...)
+}
+
+// Forgets every message sent and any queued
+// replies or error
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = nil
+	r.replies = nil
+	r.err = nil
+}
+
+// Implements http.RoundTripper, answering the
+// single and batch send APIs
+func (r *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(request)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	switch request.URL.Path {
+	case __POSTMARK_EMAIL_PATH__, __POSTMARK_TEMPLATE_PATH__:
+		var message RecordedMessage
+		if err := json.Unmarshal(body, &message); err != nil {
+			return recorderResponse(request, 422, Reply{ErrorCode: int(ErrCodeInvalidJSON), Message: err.Error()})
+		}
+		reply := r.record(message)
+		if reply.ErrorCode != 0 {
+			return recorderResponse(request, 422, reply)
+		}
+		return recorderResponse(request, 200, reply)
+	case __POSTMARK_BATCH_PATH__, __POSTMARK_BATCH_TEMPLATE_PATH__:
+		var messages []RecordedMessage
+		var err error
+		if request.URL.Path == __POSTMARK_BATCH_TEMPLATE_PATH__ {
+			var batch struct{ Messages []RecordedMessage }
+			err = json.Unmarshal(body, &batch)
+			messages = batch.Messages
+		} else {
+			err = json.Unmarshal(body, &messages)
+		}
+		if err != nil {
+			return recorderResponse(request, 422, Reply{ErrorCode: int(ErrCodeInvalidJSON), Message: err.Error()})
+		}
+		replies := make([]Reply, len(messages))
+		for i, message := range messages {
+			replies[i] = r.record(message)
+		}
+		return recorderResponse(request, 200, replies)
+	}
+
+	return recorderResponse(request, 404, Reply{Message: "Page not found"})
+}
+
+// Records a message, unless its reply rejects
+// it, and returns the reply. Must be called
+// with r.mu held
+func (r *Recorder) record(message RecordedMessage) Reply {
+	var reply Reply
+	if len(r.replies) > 0 {
+		reply = r.replies[0]
+		r.replies = r.replies[1:]
+	}
+	if reply.ErrorCode != 0 {
+		return reply
+	}
+
+	r.sent = append(r.sent, message)
+
+	r.nextID++
+	if reply.MessageID == "" {
+		reply.MessageID = fmt.Sprintf("recorded-%d", r.nextID)
+	}
+	if reply.Message == "" {
+		reply.Message = "OK"
+	}
+	if reply.To == "" {
+		reply.To = message.To
+	}
+	return reply
+}
+
+func recorderResponse(request *http.Request, status int, v interface{}) (*http.Response, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}, nil
+}
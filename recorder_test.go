@@ -0,0 +1,82 @@
+package postmark
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+	c, _ := NewClient("1234567", WithHTTPClient(recorder.Client()))
+
+	p := testMail()
+	p.AddCustomHeader("X-H1", "Dave Rulez")
+	p.AddAttachmentFromBytes("notes.txt", []byte("hello"), "text/plain")
+	reply, err := c.Send(p)
+	if err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+	if reply.MessageID == "" {
+		t.Errorf("Expected a generated MessageID")
+	}
+
+	sent := recorder.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 recorded message, got %d", len(sent))
+	}
+	m := sent[0]
+	if m.To != p.To || m.Subject != p.Subject || m.TextBody != p.TextBody {
+		t.Errorf("Unexpected recorded message %+v", m)
+	}
+	if len(m.Headers) != 1 || m.Headers[0].Value != "Dave Rulez" {
+		t.Errorf("Expected the custom header, got %+v", m.Headers)
+	}
+	if len(m.Attachments) != 1 || string(m.Attachments[0].Content) != "hello" {
+		t.Errorf("Expected the decoded attachment, got %+v", m.Attachments)
+	}
+
+	recorder.QueueReplies(Reply{ErrorCode: int(ErrCodeInactiveRecipient), Message: "Inactive recipient"})
+	if _, err := c.Send(testMail()); !IsInactiveRecipient(err) {
+		t.Errorf("Expected the queued rejection, got %v", err)
+	}
+
+	recorder.QueueReplies(Reply{}, Reply{ErrorCode: int(ErrCodeInactiveRecipient)})
+	replies, err := c.SendBatch([]*PMMail{testMail(), testMail()})
+	if err != nil || replies[0].Err() != nil || !IsInactiveRecipient(replies[1].Err()) {
+		t.Errorf("Expected per-message batch replies, got %+v and %v", replies, err)
+	}
+	if n := len(recorder.Sent()); n != 2 {
+		t.Errorf("Expected rejected messages not to be recorded, got %d messages", n)
+	}
+
+	failure := errors.New("connection refused")
+	recorder.FailWith(failure)
+	if _, err := c.Send(testMail()); !errors.Is(err, failure) {
+		t.Errorf("Expected the queued failure, got %v", err)
+	}
+
+	recorder.Reset()
+	if n := len(recorder.Sent()); n != 0 {
+		t.Errorf("Expected Reset to forget messages, got %d", n)
+	}
+}
+
+func TestRecorderConcurrent(t *testing.T) {
+	recorder := NewRecorder()
+	c, _ := NewClient("1234567", WithHTTPClient(recorder.Client()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Send(testMail())
+		}()
+	}
+	wg.Wait()
+
+	if n := len(recorder.Sent()); n != 10 {
+		t.Errorf("Expected 10 recorded messages, got %d", n)
+	}
+}
@@ -0,0 +1,42 @@
+package postmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const __POSTMARK_SERVER_PATH__ string = "/server"
+
+// Checks that Postmark accepts the client's
+// server token by fetching the token's server,
+// such as in a startup health check, so a bad
+// token fails fast rather than on the first
+// send. A rejected token returns a
+// PostmarkError whose IsBadAPIToken is true.
+// With WithTokens, every token is checked
+func (c *Client) VerifyToken() error {
+	return c.VerifyTokenContext(context.Background())
+}
+
+// Same as VerifyToken, but the requests are
+// bound to ctx
+func (c *Client) VerifyTokenContext(ctx context.Context) error {
+	if len(c.tokens) == 0 {
+		return c.verifyToken(ctx)
+	}
+
+	for i, token := range c.tokens {
+		derived := *c
+		derived.apiKey = token
+		if err := derived.verifyToken(ctx); err != nil {
+			return fmt.Errorf("%w (token %d)", err, i)
+		}
+	}
+	return nil
+}
+
+func (c *Client) verifyToken(ctx context.Context) error {
+	var server json.RawMessage
+	return c.getJSON(ctx, __POSTMARK_SERVER_PATH__, &server)
+}
@@ -0,0 +1,47 @@
+package postmark
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVerifyToken(t *testing.T) {
+	var method, path string
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Postmark-Server-Token")
+		method, path, tokens = r.Method, r.URL.Path, append(tokens, token)
+		if token != "1234567" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ErrorCode": 10, "Message": "Bad or missing API token"}`))
+			return
+		}
+		w.Write([]byte(`{"ID": 1, "Name": "Staging"}`))
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(server.URL))
+	if err := c.VerifyToken(); err != nil {
+		t.Errorf("Unexpected error verifying token: %s", err)
+	}
+	if method != "GET" || path != "/server" {
+		t.Errorf("Unexpected request %s %s", method, path)
+	}
+
+	c, _ = NewClient("bad", WithEndpoint(server.URL))
+	if err := c.VerifyToken(); !IsErrorCode(err, ErrCodeBadAPIToken) {
+		t.Errorf("Expected a bad token error, got %v", err)
+	}
+
+	tokens = nil
+	c, _ = NewClient("unused", WithEndpoint(server.URL), WithTokens("1234567", "bad"))
+	err := c.VerifyToken()
+	if !IsErrorCode(err, ErrCodeBadAPIToken) || !strings.Contains(err.Error(), "token 1") {
+		t.Errorf("Expected the second token to be rejected, got %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Errorf("Expected every token to be checked, got %v", tokens)
+	}
+}
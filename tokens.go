@@ -0,0 +1,138 @@
+package postmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// Chooses which of n server tokens sends a
+// message with the given tag, returning an
+// index from 0 to n-1. See WithTokenSelector
+type TokenSelector func(tag string, n int) int
+
+// Spreads sends across several server tokens,
+// for sharding mail over Postmark servers, in
+// place of the client's own token. Sends go to
+// each in turn unless WithTokenSelector picks
+// one, and each Reply's TokenIndex records
+// which token sent it. A batch chunk is sent
+// with a single token. It can't be combined
+// with WithSandbox, as sending with real
+// tokens would deliver the mail
+func WithTokens(tokens ...string) Option {
+	return func(c *Client) error {
+		if c.Sandbox() {
+			return fmt.Errorf("Cannot create a sandbox client with several server tokens (WithTokens and WithSandbox options)")
+		}
+		if len(tokens) == 0 {
+			return fmt.Errorf("Cannot create a client without a server token")
+		}
+		for i, token := range tokens {
+			if strings.TrimSpace(token) == "" {
+				return fmt.Errorf("Cannot create a client with a blank server token (token %d)", i)
+			}
+		}
+		c.apiKey = tokens[0]
+		c.tokens = append([]string(nil), tokens...)
+		c.nextToken = new(uint32)
+		return nil
+	}
+}
+
+// Picks the token WithTokens sends each message
+// with by calling selector, such as with a hash
+// of the message's Tag, instead of taking them
+// in turn
+func WithTokenSelector(selector TokenSelector) Option {
+	return func(c *Client) error {
+		c.tokenSelector = selector
+		return nil
+	}
+}
+
+// Retries a send that fails because of the
+// Postmark server, rather than the message,
+// with the next of the WithTokens tokens, until
+// every token has been tried. Only failures
+// that can't have sent the message fail over,
+// such as a refused connection or a 5xx
+// response, so it is never sent twice
+func WithTokenFailover() Option {
+	return func(c *Client) error {
+		c.tokenFailover = true
+		return nil
+	}
+}
+
+// Calls send with a client for the token chosen
+// for a message with the given tag and that
+// token's index, failing over to the next
+// tokens if configured. Without WithTokens,
+// send is called once with c and index 0
+func (c *Client) withTokens(tag string, send func(c *Client, index int) error) error {
+	n := len(c.tokens)
+	if n == 0 {
+		return send(c, 0)
+	}
+
+	var start int
+	if c.tokenSelector != nil {
+		start = c.tokenSelector(tag, n)
+		if start < 0 || start >= n {
+			return fmt.Errorf("Cannot send with token %d of %d (WithTokenSelector option)", start, n)
+		}
+	} else {
+		start = int((atomic.AddUint32(c.nextToken, 1) - 1) % uint32(n))
+	}
+
+	var err error
+	for i := 0; i < n; i++ {
+		index := (start + i) % n
+		derived := *c
+		derived.apiKey = c.tokens[index]
+
+		err = send(&derived, index)
+		if err == nil || !c.tokenFailover || !isServerFailure(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// Reports whether a send failed because of the
+// Postmark server or the connection to it in a
+// way that means the message can't have been
+// sent, so another server can safely send it:
+// a connection that couldn't be made, a 5xx
+// response or an account-level error code.
+// Any other failure, such as a timeout or an
+// undecodable reply, may follow a send
+func isServerFailure(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pmErr *PostmarkError
+	if errors.As(err, &pmErr) {
+		if pmErr.StatusCode >= 500 {
+			return true
+		}
+		switch pmErr.ErrorCode {
+		case ErrCodeBadAPIToken, ErrCodeNotAllowedToSend, ErrCodeAccountPending, ErrCodeAccountMayNotSend:
+			return true
+		}
+		return false
+	}
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status >= 500
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
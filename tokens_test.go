@@ -0,0 +1,119 @@
+package postmark
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWithTokens(t *testing.T) {
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Postmark-Server-Token")
+		tokens = append(tokens, token)
+		if token == "down" {
+			w.WriteHeader(503)
+			return
+		}
+		if token == "maintenance" {
+			w.WriteHeader(500)
+			w.Write([]byte(`{"ErrorCode": 100, "Message": "Maintenance"}`))
+			return
+		}
+		if r.URL.Path == "/email/batch" {
+			w.Write([]byte(`[{"ErrorCode": 0}]`))
+			return
+		}
+		w.Write([]byte(`{"ErrorCode": 0}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient("a", WithEndpoint(server.URL), WithTokens("a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Unexpected error creating client: %s", err)
+	}
+	for i := 0; i < 4; i++ {
+		reply, err := c.Send(testMail())
+		if err != nil || reply.TokenIndex != i%3 {
+			t.Errorf("Expected send %d with token %d, got %+v and %v", i, i%3, reply, err)
+		}
+	}
+	replies, err := c.SendBatch([]*PMMail{testMail()})
+	if err != nil || replies[0].TokenIndex != 1 {
+		t.Errorf("Expected the batch with token 1, got %+v and %v", replies, err)
+	}
+	if expected := "a b c a b"; strings.Join(tokens, " ") != expected {
+		t.Errorf("Expected tokens %q, got %q", expected, strings.Join(tokens, " "))
+	}
+
+	tokens = nil
+	c, _ = NewClient("down", WithEndpoint(server.URL), WithTokens("down", "up"), WithTokenFailover(),
+		WithTokenSelector(func(tag string, n int) int { return 0 }))
+	reply, err := c.Send(testMail())
+	if err != nil || reply.TokenIndex != 1 || strings.Join(tokens, " ") != "down up" {
+		t.Errorf("Expected a failover to the second token, got %+v and %v with %q", reply, err, strings.Join(tokens, " "))
+	}
+
+	tokens = nil
+	c, _ = NewClient("maintenance", WithEndpoint(server.URL), WithTokens("maintenance", "up"), WithTokenFailover(),
+		WithTokenSelector(func(tag string, n int) int { return 0 }))
+	if reply, err := c.Send(testMail()); err != nil || reply.TokenIndex != 1 {
+		t.Errorf("Expected a Postmark error from a 500 to fail over, got %+v and %v", reply, err)
+	}
+
+	if _, err := NewClient("a", WithTokens("a", " ")); err == nil {
+		t.Errorf("Expected an error for a blank token")
+	}
+
+	if _, err := NewSandboxClient(WithTokens("a", "b")); err == nil {
+		t.Errorf("Expected an error for a sandbox client with tokens")
+	}
+	if _, err := NewClient("a", WithSandbox(), WithTokens("a", "b")); err == nil {
+		t.Errorf("Expected an error for WithTokens after WithSandbox")
+	}
+	if _, err := NewClient("a", WithTokens("a", "b"), WithSandbox()); err == nil {
+		t.Errorf("Expected an error for WithSandbox after WithTokens")
+	}
+}
+
+func TestIsServerFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`not json`))
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	c, _ := NewClient("1234567", WithEndpoint(closed.URL))
+	_, dialErr := c.Send(testMail())
+	c, _ = NewClient("1234567", WithEndpoint(server.URL))
+	_, undecodable := c.Send(testMail())
+
+	for _, test := range []struct {
+		name     string
+		err      error
+		failover bool
+	}{
+		{"a refused connection", dialErr, true},
+		{"a 503 without an error code", statusError(503, "Service unavailable", nil), true},
+		{"a 500 with an error code", &PostmarkError{StatusCode: 500, ErrorCode: 100}, true},
+		{"a bad token", &PostmarkError{StatusCode: 401, ErrorCode: ErrCodeBadAPIToken}, true},
+		{"an invalid message", &PostmarkError{StatusCode: 422, ErrorCode: ErrCodeInvalidEmailRequest}, false},
+		{"a 404 without an error code", statusError(404, "Page not found", nil), false},
+		{"an undecodable 200 reply", undecodable, false},
+		{"a timeout", fmt.Errorf("%w after 1s: %w", ErrTimeout, context.DeadlineExceeded), false},
+		{"a broken connection", &url.Error{Op: "Post", URL: server.URL, Err: io.ErrUnexpectedEOF}, false},
+	} {
+		if test.err == nil {
+			t.Fatalf("Expected an error for %s", test.name)
+		}
+		if isServerFailure(test.err) != test.failover {
+			t.Errorf("Expected failover %t for %s: %v", test.failover, test.name, test.err)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package postmark
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The RecordType of each kind of event webhook
+const (
+	RecordTypeDelivery      string = "Delivery"
+	RecordTypeBounce        string = "Bounce"
+	RecordTypeOpen          string = "Open"
+	RecordTypeClick         string = "Click"
+	RecordTypeSpamComplaint string = "SpamComplaint"
+)
+
+// The fields every event webhook carries, for
+// correlating it with the message it is about
+type WebhookEvent struct {
+	RecordType    string
+	ServerID      int64
+	MessageStream string
+	MessageID     string
+
+	// The address the event is about. Bounce
+	// and spam complaint webhooks name it Email,
+	// and it is copied here
+	Recipient string
+
+	Tag      string
+	Metadata map[string]string
+}
+
+// A message delivered to the receiving server
+type DeliveryEvent struct {
+	WebhookEvent
+	DeliveredAt time.Time
+	Details     string
+}
+
+// A message that bounced, with the same fields
+// as a Bounce from Client.ListBounces
+type BounceEvent struct {
+	WebhookEvent
+	ID            int64
+	Type          string
+	TypeCode      int
+	Name          string
+	Description   string
+	Details       string
+	Email         string
+	From          string
+	Subject       string
+	Content       string
+	BouncedAt     time.Time
+	DumpAvailable bool
+	Inactive      bool
+	CanActivate   bool
+}
+
+// A recipient marking a message as spam, which
+// makes their address inactive
+type SpamComplaintEvent BounceEvent
+
+// The software a message was opened or clicked
+// with, as reported by Postmark
+type WebhookClient struct {
+	Name    string
+	Company string
+	Family  string
+}
+
+// Where a message was opened or clicked, as
+// located by Postmark from the IP address
+type WebhookGeo struct {
+	CountryISOCode string
+	Country        string
+	RegionISOCode  string
+	Region         string
+	City           string
+	Zip            string
+	Coords         string
+	IP             string
+}
+
+// A message being opened, when open tracking
+// is on
+type OpenEvent struct {
+	WebhookEvent
+	FirstOpen   bool
+	ReceivedAt  time.Time
+	Platform    string
+	ReadSeconds int
+	UserAgent   string
+	Client      WebhookClient
+	OS          WebhookClient
+	Geo         WebhookGeo
+}
+
+// A tracked link in a message being clicked
+type ClickEvent struct {
+	WebhookEvent
+	ClickLocation string
+	OriginalLink  string
+	ReceivedAt    time.Time
+	Platform      string
+	UserAgent     string
+	Client        WebhookClient
+	OS            WebhookClient
+	Geo           WebhookGeo
+}
+
+// A sender or recipient of an inbound message
+type InboundAddress struct {
+	Email       string
+	Name        string
+	MailboxHash string
+}
+
+// An attachment on an inbound message, with its
+// content in base64 as Postmark sends it
+type InboundAttachment struct {
+	Name          string
+	Content       string
+	ContentType   string
+	ContentLength int
+	ContentID     string
+}
+
+// An e-mail received by an inbound stream
+type InboundMessage struct {
+	MessageStream     string
+	MessageID         string
+	From              string
+	FromName          string
+	FromFull          InboundAddress
+	To                string
+	ToFull            []InboundAddress
+	Cc                string
+	CcFull            []InboundAddress
+	Bcc               string
+	BccFull           []InboundAddress
+	OriginalRecipient string
+	ReplyTo           string
+	Subject           string
+
+	// The Date header, as it was sent
+	Date string
+
+	MailboxHash       string
+	TextBody          string
+	HtmlBody          string
+	StrippedTextReply string
+	Tag               string
+	Headers           []Header
+	Attachments       []InboundAttachment
+}
+
+// Parses a delivery webhook's body
+func ParseDeliveryWebhook(r io.Reader) (*DeliveryEvent, error) {
+	event := &DeliveryEvent{}
+	if err := decodeWebhook(r, RecordTypeDelivery, event, &event.WebhookEvent); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Parses a bounce webhook's body
+func ParseBounceWebhook(r io.Reader) (*BounceEvent, error) {
+	event := &BounceEvent{}
+	if err := decodeWebhook(r, RecordTypeBounce, event, &event.WebhookEvent); err != nil {
+		return nil, err
+	}
+	event.Recipient = event.Email
+	return event, nil
+}
+
+// Parses a spam complaint webhook's body
+func ParseSpamComplaintWebhook(r io.Reader) (*SpamComplaintEvent, error) {
+	event := &SpamComplaintEvent{}
+	if err := decodeWebhook(r, RecordTypeSpamComplaint, event, &event.WebhookEvent); err != nil {
+		return nil, err
+	}
+	event.Recipient = event.Email
+	return event, nil
+}
+
+// Parses an open webhook's body
+func ParseOpenWebhook(r io.Reader) (*OpenEvent, error) {
+	event := &OpenEvent{}
+	if err := decodeWebhook(r, RecordTypeOpen, event, &event.WebhookEvent); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Parses a click webhook's body
+func ParseClickWebhook(r io.Reader) (*ClickEvent, error) {
+	event := &ClickEvent{}
+	if err := decodeWebhook(r, RecordTypeClick, event, &event.WebhookEvent); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Parses an inbound webhook's body
+func ParseInboundWebhook(r io.Reader) (*InboundMessage, error) {
+	message := &InboundMessage{}
+	if err := json.NewDecoder(r).Decode(message); err != nil {
+		return nil, fmt.Errorf("Cannot parse inbound webhook: %s", err)
+	}
+	return message, nil
+}
+
+// Parses an event webhook of any type, for an
+// endpoint that receives several, returning a
+// *DeliveryEvent, *BounceEvent, *OpenEvent,
+// *ClickEvent or *SpamComplaintEvent
+func ParseWebhook(r io.Reader) (interface{}, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("Cannot parse webhook: %s", err)
+	}
+	var event WebhookEvent
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return nil, fmt.Errorf("Cannot parse webhook: %s", err)
+	}
+
+	switch event.RecordType {
+	case RecordTypeDelivery:
+		return ParseDeliveryWebhook(bytes.NewReader(raw))
+	case RecordTypeBounce:
+		return ParseBounceWebhook(bytes.NewReader(raw))
+	case RecordTypeOpen:
+		return ParseOpenWebhook(bytes.NewReader(raw))
+	case RecordTypeClick:
+		return ParseClickWebhook(bytes.NewReader(raw))
+	case RecordTypeSpamComplaint:
+		return ParseSpamComplaintWebhook(bytes.NewReader(raw))
+	}
+	return nil, fmt.Errorf("Cannot parse webhook of unknown type %q (.RecordType field)", event.RecordType)
+}
+
+// Decodes a webhook into v, checking that the
+// RecordType decoded into event is recordType
+func decodeWebhook(r io.Reader, recordType string, v interface{}, event *WebhookEvent) error {
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("Cannot parse %s webhook: %s", recordType, err)
+	}
+	if event.RecordType != recordType {
+		return fmt.Errorf("Cannot parse %q webhook as %s (.RecordType field)", event.RecordType, recordType)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package postmark
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBounceWebhook(t *testing.T) {
+	body := `{
+		"RecordType": "Bounce",
+		"MessageStream": "outbound",
+		"ID": 4323372036854775807,
+		"Type": "HardBounce",
+		"TypeCode": 1,
+		"Name": "Hard bounce",
+		"Tag": "Invitation",
+		"MessageID": "883953f4-6105-42a2-a16a-77a8eac79483",
+		"Metadata": {"a_key": "a_value"},
+		"ServerID": 23,
+		"Description": "The server was unable to deliver your message (ex: unknown user, mailbox not found).",
+		"Email": "john@example.com",
+		"From": "sender@example.com",
+		"BouncedAt": "2019-11-05T16:33:54.9070259Z",
+		"DumpAvailable": true,
+		"Inactive": true,
+		"CanActivate": true,
+		"Subject": "Test subject"
+	}`
+
+	event, err := ParseBounceWebhook(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing bounce webhook: %s", err)
+	}
+	if event.MessageID != "883953f4-6105-42a2-a16a-77a8eac79483" || event.Recipient != "john@example.com" || event.Tag != "Invitation" || event.Metadata["a_key"] != "a_value" {
+		t.Errorf("Unexpected common fields %+v", event.WebhookEvent)
+	}
+	if event.ID != 4323372036854775807 || event.Type != "HardBounce" || !event.Inactive || event.BouncedAt.Year() != 2019 {
+		t.Errorf("Unexpected bounce fields %+v", event)
+	}
+
+	if _, err := ParseDeliveryWebhook(strings.NewReader(body)); err == nil || !strings.Contains(err.Error(), ".RecordType field") {
+		t.Errorf("Expected a record type error parsing a bounce as a delivery, got %v", err)
+	}
+	if _, err := ParseBounceWebhook(strings.NewReader("{")); err == nil {
+		t.Errorf("Expected an error for truncated JSON")
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	tests := map[string]func(interface{}) bool{
+		`{"RecordType": "Delivery", "Recipient": "john@example.com", "DeliveredAt": "2019-11-05T16:33:54Z", "Details": "Test delivery webhook details"}`: func(v interface{}) bool {
+			e, ok := v.(*DeliveryEvent)
+			return ok && e.Recipient == "john@example.com" && e.Details == "Test delivery webhook details"
+		},
+		`{"RecordType": "Open", "FirstOpen": true, "Recipient": "john@example.com", "Client": {"Name": "Chrome 35.0.1916.153"}, "Geo": {"City": "Novi Sad"}}`: func(v interface{}) bool {
+			e, ok := v.(*OpenEvent)
+			return ok && e.FirstOpen && e.Client.Name == "Chrome 35.0.1916.153" && e.Geo.City == "Novi Sad"
+		},
+		`{"RecordType": "Click", "ClickLocation": "HTML", "OriginalLink": "https://example.com", "Recipient": "john@example.com"}`: func(v interface{}) bool {
+			e, ok := v.(*ClickEvent)
+			return ok && e.OriginalLink == "https://example.com"
+		},
+		`{"RecordType": "SpamComplaint", "Type": "SpamComplaint", "Email": "john@example.com"}`: func(v interface{}) bool {
+			e, ok := v.(*SpamComplaintEvent)
+			return ok && e.Recipient == "john@example.com"
+		},
+	}
+	for body, check := range tests {
+		event, err := ParseWebhook(strings.NewReader(body))
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %s", body, err)
+			continue
+		}
+		if !check(event) {
+			t.Errorf("Unexpected event %+v parsed from %s", event, body)
+		}
+	}
+
+	if _, err := ParseWebhook(strings.NewReader(`{"RecordType": "Unknown"}`)); err == nil {
+		t.Errorf("Expected an error for an unknown record type")
+	}
+}
+
+func TestParseInboundWebhook(t *testing.T) {
+	body := `{
+		"FromName": "Postmarkapp Support",
+		"MessageStream": "inbound",
+		"From": "support@postmarkapp.com",
+		"FromFull": {"Email": "support@postmarkapp.com", "Name": "Postmarkapp Support", "MailboxHash": ""},
+		"To": "\"Firstname Lastname\" <yourhash+SampleHash@inbound.postmarkapp.com>",
+		"ToFull": [{"Email": "yourhash+SampleHash@inbound.postmarkapp.com", "Name": "Firstname Lastname", "MailboxHash": "SampleHash"}],
+		"Subject": "Test subject",
+		"MessageID": "73e6d360-66eb-11e1-8e72-a8904824019b",
+		"MailboxHash": "SampleHash",
+		"Date": "Fri, 1 Aug 2014 16:45:32 -04:00",
+		"TextBody": "This is a test text body.",
+		"Headers": [{"Name": "X-Spam-Status", "Value": "No"}],
+		"Attachments": [{"Name": "test.txt", "Content": "VGhpcyBpcyBhdHRhY2htZW50IGNvbnRlbnRzLCBiYXNlLTY0IGVuY29kZWQu", "ContentType": "text/plain", "ContentLength": 45}]
+	}`
+
+	message, err := ParseInboundWebhook(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing inbound webhook: %s", err)
+	}
+	if message.FromFull.Name != "Postmarkapp Support" || len(message.ToFull) != 1 || message.ToFull[0].MailboxHash != "SampleHash" {
+		t.Errorf("Unexpected addresses %+v and %+v", message.FromFull, message.ToFull)
+	}
+	if len(message.Headers) != 1 || message.Headers[0] != (Header{"X-Spam-Status", "No"}) || len(message.Attachments) != 1 || message.Attachments[0].ContentLength != 45 {
+		t.Errorf("Unexpected headers %v or attachments %+v", message.Headers, message.Attachments)
+	}
+}